@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert 发往告警通道的事件
+type Alert struct {
+	Source     string      `json:"source"` // key_age、health
+	Severity   string      `json:"severity"`
+	SysID      string      `json:"sys_id"`
+	Message    string      `json:"message"`
+	Details    interface{} `json:"details,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// AlertChannel 告警通道：写入日志，设置webhook地址时同时以JSON POST到该地址
+type AlertChannel struct {
+	webhookURL string
+	httpClient *http.Client
+}
+
+// NewAlertChannel 创建告警通道，webhookURL为空时只写日志
+func NewAlertChannel(webhookURL string) *AlertChannel {
+	return &AlertChannel{
+		webhookURL: webhookURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send 发送告警，webhook在后台投递，失败只记录日志
+func (a *AlertChannel) Send(alert Alert) {
+	log.Printf("ALERT [%s] %s sys_id=%s: %s", alert.Severity, alert.Source, alert.SysID, alert.Message)
+	if a.webhookURL == "" {
+		return
+	}
+	go func() {
+		if err := a.post(alert); err != nil {
+			log.Printf("Failed to deliver alert to webhook: %v", err)
+		}
+	}()
+}
+
+func (a *AlertChannel) post(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, a.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// keyAgeAlert 私钥期限状态变化的告警
+func keyAgeAlert(event KeyAgeEvent) Alert {
+	alert := Alert{
+		Source:     "key_age",
+		Severity:   SeverityInfo,
+		SysID:      event.SysID,
+		Details:    event,
+		OccurredAt: event.OccurredAt,
+	}
+	expiresAt := event.Key.ExpiresAt.Format("2006-01-02")
+	switch event.To {
+	case KeyStatusExpiring:
+		alert.Severity = SeverityWarning
+		alert.Message = fmt.Sprintf("%s private key is %d days old and expires on %s, rotate it soon", event.Key.Environment, event.Key.AgeDays, expiresAt)
+	case KeyStatusExpired:
+		alert.Severity = SeverityCritical
+		alert.Message = fmt.Sprintf("%s private key is %d days old and expired on %s, rotate it now", event.Key.Environment, event.Key.AgeDays, expiresAt)
+	default:
+		alert.Message = fmt.Sprintf("%s private key is within its maximum age again", event.Key.Environment)
+	}
+	return alert
+}
+
+// healthAlert 健康状态变化的告警
+func healthAlert(event HealthEvent) Alert {
+	severity := SeverityInfo
+	switch event.To {
+	case HealthDegraded:
+		severity = SeverityWarning
+	case HealthUnhealthy:
+		severity = SeverityCritical
+	}
+	message := fmt.Sprintf("health changed from %s to %s", event.From, event.To)
+	if event.Reason != "" {
+		message += ": " + event.Reason
+	}
+	return Alert{
+		Source:     "health",
+		Severity:   severity,
+		SysID:      event.SysID,
+		Message:    message,
+		Details:    event,
+		OccurredAt: event.OccurredAt,
+	}
+}
+
+var alertChannel = NewAlertChannel(os.Getenv("HUIFU_ALERT_WEBHOOK_URL"))
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type actorKey struct{}
+
+type stepUpKey struct{}
+
+// WithActor 将操作员写入context
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext 读取context中的操作员
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// withStepUp 标记本次请求已通过TOTP二次验证
+func withStepUp(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stepUpKey{}, true)
+}
+
+// steppedUp 判断本次请求是否已通过TOTP二次验证
+func steppedUp(ctx context.Context) bool {
+	ok, _ := ctx.Value(stepUpKey{}).(bool)
+	return ok
+}
+
+// requestActor gin中间件：将 X-Operator-ID 写入请求context
+func requestActor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := strings.TrimSpace(c.GetHeader(operatorHeader)); actor != "" {
+			c.Request = c.Request.WithContext(WithActor(c.Request.Context(), actor))
+		}
+		c.Next()
+	}
+}
+
+// AuditEntry 一次写操作的审计记录
+type AuditEntry struct {
+	SysID      string                 `json:"sys_id"`
+	Endpoint   string                 `json:"endpoint"`
+	Actor      string                 `json:"actor"`
+	StepUp     bool                   `json:"step_up"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	ReqSeqID   string                 `json:"req_seq_id,omitempty"`
+	Params     map[string]interface{} `json:"params"`
+	Success    bool                   `json:"success"`
+	Error      string                 `json:"error,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// AuditLog 内存中的审计记录
+type AuditLog struct {
+	mu         sync.Mutex
+	entries    []AuditEntry
+	maxEntries int
+}
+
+// NewAuditLog 创建审计记录
+func NewAuditLog(maxEntries int) *AuditLog {
+	return &AuditLog{maxEntries: maxEntries}
+}
+
+// Record 追加一条审计记录
+func (a *AuditLog) Record(entry AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > a.maxEntries {
+		a.entries = a.entries[len(a.entries)-a.maxEntries:]
+	}
+}
+
+// Entries 按时间倒序返回最多limit条记录
+func (a *AuditLog) Entries(limit int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := make([]AuditEntry, 0, len(a.entries))
+	for i := len(a.entries) - 1; i >= 0 && (limit <= 0 || len(entries) < limit); i-- {
+		entries = append(entries, a.entries[i])
+	}
+	return entries
+}
+
+var auditLog = NewAuditLog(1000)
+
+// AuditMiddleware 记录非幂等写操作的操作员、二次验证情况及结果
+func AuditMiddleware(sysID string, audit *AuditLog) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		spec, err := endpointRegistry.Lookup(endpoint)
+		if err != nil || spec.Idempotent {
+			return next.CallAPI(ctx, endpoint, params)
+		}
+
+		result, err := next.CallAPI(ctx, endpoint, params)
+
+		entry := AuditEntry{
+			SysID:      sysID,
+			Endpoint:   endpoint,
+			Actor:      ActorFromContext(ctx),
+			StepUp:     steppedUp(ctx),
+			TraceID:    TraceIDFromContext(ctx),
+			ReqSeqID:   stringField(params, "req_seq_id"),
+			Params:     redactParams(params),
+			OccurredAt: time.Now(),
+		}
+		outcome := err
+		if outcome == nil {
+			outcome = CheckResponse(endpoint, result)
+			if entry.ReqSeqID == "" {
+				entry.ReqSeqID = stringField(responseData(result), "req_seq_id")
+			}
+		}
+		if outcome != nil {
+			entry.Error = outcome.Error()
+		} else {
+			entry.Success = true
+		}
+		audit.Record(entry)
+
+		return result, err
+	})
+}
+
+// getAuditLog 查询审计记录
+func getAuditLog(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	entries := auditLog.Entries(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
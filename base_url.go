@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// huifuHostSuffixes SDK访问的汇付域名（生产及联调环境）
+var huifuHostSuffixes = []string{"huifu.com", "cloudpnr.com"}
+
+// baseURLTransport 将发往汇付域名的请求改写到指定地址，其余请求原样转发
+// SDK内部使用默认HTTP客户端且不支持配置地址，因此在 http.DefaultTransport 上改写
+type baseURLTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isHuifuHost(req.URL.Hostname()) {
+		return t.next.RoundTrip(req)
+	}
+
+	rewritten := req.Clone(req.Context())
+	rewritten.URL.Scheme = t.target.Scheme
+	rewritten.URL.Host = t.target.Host
+	rewritten.URL.Path = strings.TrimSuffix(t.target.Path, "/") + req.URL.Path
+	rewritten.Host = t.target.Host
+	return t.next.RoundTrip(rewritten)
+}
+
+func isHuifuHost(host string) bool {
+	for _, suffix := range huifuHostSuffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// installBaseURLOverride 按 HUIFU_BASE_URL 将SDK请求改写到本地模拟服务等地址
+func installBaseURLOverride() error {
+	baseURL := os.Getenv("HUIFU_BASE_URL")
+	if baseURL == "" {
+		return nil
+	}
+
+	target, err := url.Parse(baseURL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return fmt.Errorf("invalid HUIFU_BASE_URL %q: must be an absolute URL", baseURL)
+	}
+
+	http.DefaultTransport = &baseURLTransport{
+		target: target,
+		next:   http.DefaultTransport,
+	}
+	log.Printf("Huifu API requests are redirected to %s", target)
+	return nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerConfig 熔断阈值配置
+type BreakerConfig struct {
+	FailureThreshold int           `json:"failure_threshold"` // 连续失败多少次后熔断
+	OpenDuration     time.Duration `json:"open_duration"`     // 熔断后多久进入半开探测
+	HalfOpenMaxCalls int           `json:"half_open_max_calls"`
+	SuccessThreshold int           `json:"success_threshold"` // 半开状态连续成功多少次后恢复
+}
+
+// DefaultBreakerConfig 默认熔断配置
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+		HalfOpenMaxCalls: 1,
+		SuccessThreshold: 1,
+	}
+}
+
+// LoadBreakerConfig 从环境变量加载熔断配置，未设置的项使用默认值
+//
+//	HUIFU_BREAKER_FAILURES        连续失败阈值
+//	HUIFU_BREAKER_OPEN_SECONDS    熔断持续秒数
+//	HUIFU_BREAKER_HALF_OPEN_CALLS 半开状态允许的并发探测数
+//	HUIFU_BREAKER_SUCCESSES       半开恢复所需的连续成功数
+func LoadBreakerConfig() BreakerConfig {
+	cfg := DefaultBreakerConfig()
+	if v := envInt("HUIFU_BREAKER_FAILURES"); v > 0 {
+		cfg.FailureThreshold = v
+	}
+	if v := envInt("HUIFU_BREAKER_OPEN_SECONDS"); v > 0 {
+		cfg.OpenDuration = time.Duration(v) * time.Second
+	}
+	if v := envInt("HUIFU_BREAKER_HALF_OPEN_CALLS"); v > 0 {
+		cfg.HalfOpenMaxCalls = v
+	}
+	if v := envInt("HUIFU_BREAKER_SUCCESSES"); v > 0 {
+		cfg.SuccessThreshold = v
+	}
+	return cfg
+}
+
+func envInt(name string) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// CircuitOpenError 熔断期间快速失败返回的错误
+type CircuitOpenError struct {
+	SysID     string
+	Endpoint  string
+	State     BreakerState
+	NextProbe time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.State == BreakerHalfOpen {
+		return fmt.Sprintf("circuit half-open for sys_id %s on %s: probe in progress, retry after %s",
+			e.SysID, e.Endpoint, e.NextProbe.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("circuit open for sys_id %s on %s: next probe at %s",
+		e.SysID, e.Endpoint, e.NextProbe.Format(time.RFC3339))
+}
+
+// RetryAfter 距离下次探测的时间
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	d := time.Until(e.NextProbe)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// BreakerStatus 熔断器状态快照
+type BreakerStatus struct {
+	SysID               string       `json:"sys_id"`
+	Endpoint            string       `json:"endpoint"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	NextProbe           *time.Time   `json:"next_probe,omitempty"`
+}
+
+// circuitBreaker 单个sys_id+endpoint的熔断器
+type circuitBreaker struct {
+	state     BreakerState
+	failures  int
+	successes int
+	inFlight  int // 半开状态下进行中的探测数
+	lastError string
+	openedAt  time.Time
+	nextProbe time.Time
+}
+
+type breakerKey struct {
+	sysID    string
+	endpoint string
+}
+
+// BreakerRegistry 管理所有熔断器
+type BreakerRegistry struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	breakers map[breakerKey]*circuitBreaker
+	now      func() time.Time
+}
+
+// NewBreakerRegistry 创建熔断器注册表
+func NewBreakerRegistry(config BreakerConfig) *BreakerRegistry {
+	return &BreakerRegistry{
+		config:   config,
+		breakers: make(map[breakerKey]*circuitBreaker),
+		now:      time.Now,
+	}
+}
+
+// Config 返回当前熔断配置
+func (r *BreakerRegistry) Config() BreakerConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.config
+}
+
+// SetConfig 更新熔断配置，对后续状态变化生效
+func (r *BreakerRegistry) SetConfig(config BreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = config
+}
+
+// Allow 判断调用是否放行，熔断时返回 *CircuitOpenError
+func (r *BreakerRegistry) Allow(sysID, endpoint string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.breakerLocked(sysID, endpoint)
+	now := r.now()
+
+	if b.state == BreakerOpen && !now.Before(b.nextProbe) {
+		b.state = BreakerHalfOpen
+		b.successes = 0
+		b.inFlight = 0
+		log.Printf("Circuit half-open: sys_id=%s endpoint=%s", sysID, endpoint)
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		return &CircuitOpenError{SysID: sysID, Endpoint: endpoint, State: b.state, NextProbe: b.nextProbe}
+	case BreakerHalfOpen:
+		if b.inFlight >= r.config.HalfOpenMaxCalls {
+			return &CircuitOpenError{SysID: sysID, Endpoint: endpoint, State: b.state, NextProbe: now.Add(time.Second)}
+		}
+		b.inFlight++
+	}
+	return nil
+}
+
+// Record 记录一次调用结果
+func (r *BreakerRegistry) Record(sysID, endpoint string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.breakerLocked(sysID, endpoint)
+	now := r.now()
+	if b.state == BreakerHalfOpen && b.inFlight > 0 {
+		b.inFlight--
+	}
+
+	// 调用方取消不能说明汇付是否可用，只释放探测名额，不计成功或失败
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if !countsAsBreakerFailure(err) {
+		b.failures = 0
+		if b.state == BreakerHalfOpen {
+			b.successes++
+			if b.successes >= r.config.SuccessThreshold {
+				b.state = BreakerClosed
+				b.lastError = ""
+				log.Printf("Circuit closed: sys_id=%s endpoint=%s", sysID, endpoint)
+			}
+		}
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == BreakerHalfOpen || b.failures >= r.config.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+		b.nextProbe = now.Add(r.config.OpenDuration)
+		log.Printf("Circuit opened: sys_id=%s endpoint=%s failures=%d next_probe=%s: %v",
+			sysID, endpoint, b.failures, b.nextProbe.Format(time.RFC3339), err)
+	}
+}
+
+// Reset 将熔断器恢复为关闭状态，endpoint为空时重置该sys_id的全部熔断器
+func (r *BreakerRegistry) Reset(sysID, endpoint string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for key := range r.breakers {
+		if key.sysID == sysID && (endpoint == "" || key.endpoint == endpoint) {
+			delete(r.breakers, key)
+			count++
+		}
+	}
+	return count
+}
+
+// Snapshot 返回全部熔断器状态，sysID非空时只返回该sys_id
+func (r *BreakerRegistry) Snapshot(sysID string) []BreakerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(r.breakers))
+	for key, b := range r.breakers {
+		if sysID != "" && key.sysID != sysID {
+			continue
+		}
+		status := BreakerStatus{
+			SysID:               key.sysID,
+			Endpoint:            key.endpoint,
+			State:               b.state,
+			ConsecutiveFailures: b.failures,
+			LastError:           b.lastError,
+		}
+		if b.state != BreakerClosed {
+			openedAt, nextProbe := b.openedAt, b.nextProbe
+			status.OpenedAt = &openedAt
+			status.NextProbe = &nextProbe
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].SysID != statuses[j].SysID {
+			return statuses[i].SysID < statuses[j].SysID
+		}
+		return statuses[i].Endpoint < statuses[j].Endpoint
+	})
+	return statuses
+}
+
+func (r *BreakerRegistry) breakerLocked(sysID, endpoint string) *circuitBreaker {
+	key := breakerKey{sysID: sysID, endpoint: endpoint}
+	b, exists := r.breakers[key]
+	if !exists {
+		b = &circuitBreaker{state: BreakerClosed}
+		r.breakers[key] = b
+	}
+	return b
+}
+
+// countsAsBreakerFailure 仅网络类错误计入熔断，业务拒绝说明汇付可用
+// 调用方取消的调用由 Record 单独处理
+func countsAsBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return AsHuifuError("", err).Category == CategoryNetwork
+}
+
+var breakerRegistry = NewBreakerRegistry(LoadBreakerConfig())
+
+// BreakerClient 为HuifuClient增加按sys_id+endpoint熔断的能力
+type BreakerClient struct {
+	next     HuifuClient
+	sysID    string
+	registry *BreakerRegistry
+}
+
+// NewBreakerClient 创建带熔断的客户端
+func NewBreakerClient(next HuifuClient, sysID string, registry *BreakerRegistry) *BreakerClient {
+	return &BreakerClient{next: next, sysID: sysID, registry: registry}
+}
+
+// Unwrap 返回被包装的客户端
+func (c *BreakerClient) Unwrap() HuifuClient {
+	return c.next
+}
+
+// CallAPI 熔断打开时快速失败，否则调用并记录结果
+func (c *BreakerClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	if err := c.registry.Allow(c.sysID, endpoint); err != nil {
+		return nil, err
+	}
+	result, err := c.next.CallAPI(ctx, endpoint, params)
+	c.registry.Record(c.sysID, endpoint, err)
+	return result, err
+}
+
+// getBreakers 查询熔断器状态
+func getBreakers(c *gin.Context) {
+	statuses := breakerRegistry.Snapshot(c.Query("sys_id"))
+	config := breakerRegistry.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"breakers": statuses,
+		"count":    len(statuses),
+		"config": gin.H{
+			"failure_threshold":   config.FailureThreshold,
+			"open_duration":       config.OpenDuration.String(),
+			"half_open_max_calls": config.HalfOpenMaxCalls,
+			"success_threshold":   config.SuccessThreshold,
+		},
+	})
+}
+
+// updateBreakerConfig 更新熔断阈值
+func updateBreakerConfig(c *gin.Context) {
+	var req struct {
+		FailureThreshold int `json:"failure_threshold"`
+		OpenSeconds      int `json:"open_seconds"`
+		HalfOpenMaxCalls int `json:"half_open_max_calls"`
+		SuccessThreshold int `json:"success_threshold"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	config := breakerRegistry.Config()
+	if req.FailureThreshold > 0 {
+		config.FailureThreshold = req.FailureThreshold
+	}
+	if req.OpenSeconds > 0 {
+		config.OpenDuration = time.Duration(req.OpenSeconds) * time.Second
+	}
+	if req.HalfOpenMaxCalls > 0 {
+		config.HalfOpenMaxCalls = req.HalfOpenMaxCalls
+	}
+	if req.SuccessThreshold > 0 {
+		config.SuccessThreshold = req.SuccessThreshold
+	}
+	breakerRegistry.SetConfig(config)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Breaker configuration updated",
+		"config": gin.H{
+			"failure_threshold":   config.FailureThreshold,
+			"open_duration":       config.OpenDuration.String(),
+			"half_open_max_calls": config.HalfOpenMaxCalls,
+			"success_threshold":   config.SuccessThreshold,
+		},
+	})
+}
+
+// resetBreakers 手动恢复熔断器
+func resetBreakers(c *gin.Context) {
+	var req struct {
+		SysID    string `json:"sys_id" binding:"required"`
+		Endpoint string `json:"endpoint"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	count := breakerRegistry.Reset(req.SysID, req.Endpoint)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Breakers reset",
+		"sys_id":  req.SysID,
+		"count":   count,
+	})
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	huifuIDPattern  = regexp.MustCompile(`^[0-9]{1,32}$`)
+	feeTypePattern  = regexp.MustCompile(`^0[1-9]$`)
+	wxAppIDPattern  = regexp.MustCompile(`^wx[0-9a-zA-Z]{16}$`)
+	reqSeqIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{1,128}$`)
+)
+
+// ValidationError 请求模型校验失败
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// WeChatConfigItem 单条微信配置（wx_conf_list 中的元素）
+type WeChatConfigItem struct {
+	FeeType          string `json:"fee_type,omitempty"`
+	WxWoaAppID       string `json:"wx_woa_app_id,omitempty"`
+	WxWoaPath        string `json:"wx_woa_path,omitempty"`
+	WxAppletAppID    string `json:"wx_applet_app_id,omitempty"`
+	WxSubscribeAppID string `json:"wx_subscribe_app_id,omitempty"`
+
+	// Extra 保留模型未声明的字段，保证JSON往返无损
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type weChatConfigItemAlias WeChatConfigItem
+
+func (i *WeChatConfigItem) UnmarshalJSON(data []byte) error {
+	var alias weChatConfigItemAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*i = WeChatConfigItem(alias)
+	i.Extra = extra
+	return nil
+}
+
+func (i WeChatConfigItem) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(weChatConfigItemAlias(i), i.Extra)
+}
+
+// Validate 校验单条微信配置
+func (i *WeChatConfigItem) Validate() error {
+	if i.FeeType != "" && !feeTypePattern.MatchString(i.FeeType) {
+		return &ValidationError{Field: "fee_type", Reason: "must be 01-09"}
+	}
+	for field, appID := range map[string]string{
+		"wx_woa_app_id":       i.WxWoaAppID,
+		"wx_applet_app_id":    i.WxAppletAppID,
+		"wx_subscribe_app_id": i.WxSubscribeAppID,
+	} {
+		if appID != "" && !wxAppIDPattern.MatchString(appID) {
+			return &ValidationError{Field: field, Reason: "must be a WeChat app id like wx followed by 16 characters"}
+		}
+	}
+	return nil
+}
+
+// WeChatConfigList 微信配置列表
+// 汇付有时以JSON字符串形式返回列表，StringEncoded 记录原始形式以便原样输出
+type WeChatConfigList struct {
+	Items         []WeChatConfigItem
+	StringEncoded bool
+}
+
+func (l *WeChatConfigList) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.Equal(trimmed, []byte("null")) {
+		*l = WeChatConfigList{}
+		return nil
+	}
+
+	if len(trimmed) > 0 && trimmed[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(trimmed, &encoded); err != nil {
+			return err
+		}
+		l.StringEncoded = true
+		if strings.TrimSpace(encoded) == "" {
+			l.Items = nil
+			return nil
+		}
+		trimmed = []byte(encoded)
+	}
+
+	return json.Unmarshal(trimmed, &l.Items)
+}
+
+func (l WeChatConfigList) MarshalJSON() ([]byte, error) {
+	items := l.Items
+	if items == nil {
+		items = []WeChatConfigItem{}
+	}
+	data, err := json.Marshal(items)
+	if err != nil || !l.StringEncoded {
+		return data, err
+	}
+	return json.Marshal(string(data))
+}
+
+// BusiConfigRequest /v2/merchant/busi/config 请求模型
+type BusiConfigRequest struct {
+	ReqSeqID         string                 `json:"req_seq_id,omitempty"`
+	ReqDate          string                 `json:"req_date,omitempty"`
+	HuifuID          string                 `json:"huifu_id"`
+	FeeType          string                 `json:"fee_type"`
+	WxWoaAppID       string                 `json:"wx_woa_app_id,omitempty"`
+	WxWoaPath        string                 `json:"wx_woa_path,omitempty"`
+	WxAppletAppID    string                 `json:"wx_applet_app_id,omitempty"`
+	WxSubscribeAppID string                 `json:"wx_subscribe_app_id,omitempty"`
+	ExtendInfos      map[string]interface{} `json:"extend_infos,omitempty"`
+
+	// Extra 保留模型未声明的字段，仅用于JSON往返无损，不随请求发往汇付
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type busiConfigRequestAlias BusiConfigRequest
+
+// NewBusiConfigRequest 创建并校验微信配置请求
+func NewBusiConfigRequest(huifuID, feeType, wxWoaAppID, wxWoaPath string) (*BusiConfigRequest, error) {
+	req := &BusiConfigRequest{
+		HuifuID:    huifuID,
+		FeeType:    feeType,
+		WxWoaAppID: wxWoaAppID,
+		WxWoaPath:  wxWoaPath,
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (r *BusiConfigRequest) UnmarshalJSON(data []byte) error {
+	var alias busiConfigRequestAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*r = BusiConfigRequest(alias)
+	r.Extra = extra
+	return nil
+}
+
+func (r BusiConfigRequest) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(busiConfigRequestAlias(r), r.Extra)
+}
+
+// Validate 校验微信配置请求
+func (r *BusiConfigRequest) Validate() error {
+	if err := validateHuifuID(r.HuifuID); err != nil {
+		return err
+	}
+	if err := validateReqSeq(r.ReqSeqID, r.ReqDate); err != nil {
+		return err
+	}
+	if r.FeeType == "" {
+		return &ValidationError{Field: "fee_type", Reason: "is required"}
+	}
+	if r.WxWoaAppID == "" && r.WxAppletAppID == "" && r.WxSubscribeAppID == "" {
+		return &ValidationError{Field: "wx_woa_app_id", Reason: "at least one WeChat app id is required"}
+	}
+	if r.WxWoaAppID != "" && strings.TrimSpace(r.WxWoaPath) == "" {
+		return &ValidationError{Field: "wx_woa_path", Reason: "is required when wx_woa_app_id is set"}
+	}
+	if len(r.WxWoaPath) > 256 {
+		return &ValidationError{Field: "wx_woa_path", Reason: "must not exceed 256 characters"}
+	}
+
+	item := WeChatConfigItem{
+		FeeType:          r.FeeType,
+		WxWoaAppID:       r.WxWoaAppID,
+		WxAppletAppID:    r.WxAppletAppID,
+		WxSubscribeAppID: r.WxSubscribeAppID,
+	}
+	return item.Validate()
+}
+
+// ToParams 转换为 HuifuClient.CallAPI 使用的参数，extend_infos 平铺到顶层，未声明的字段不发送
+func (r *BusiConfigRequest) ToParams() map[string]interface{} {
+	params := make(map[string]interface{})
+	for k, v := range r.ExtendInfos {
+		params[k] = v
+	}
+
+	params["huifu_id"] = r.HuifuID
+	params["fee_type"] = r.FeeType
+	setIfNotEmpty(params, "req_seq_id", r.ReqSeqID)
+	setIfNotEmpty(params, "req_date", r.ReqDate)
+	setIfNotEmpty(params, "wx_woa_app_id", r.WxWoaAppID)
+	setIfNotEmpty(params, "wx_woa_path", r.WxWoaPath)
+	setIfNotEmpty(params, "wx_applet_app_id", r.WxAppletAppID)
+	setIfNotEmpty(params, "wx_subscribe_app_id", r.WxSubscribeAppID)
+	return params
+}
+
+// SDKExtendInfos 返回传给SDK ExtendInfos的字段（除流水号与huifu_id外的全部业务字段）
+func (r *BusiConfigRequest) SDKExtendInfos() map[string]interface{} {
+	infos := r.ToParams()
+	delete(infos, "huifu_id")
+	delete(infos, "req_seq_id")
+	delete(infos, "req_date")
+	return infos
+}
+
+// BusiConfigRequestFromParams 从CallAPI参数解析并校验微信配置请求
+// CallAPI参数中的 extend_infos 已平铺到顶层，未声明的字段还原为 ExtendInfos
+func BusiConfigRequestFromParams(params map[string]interface{}) (*BusiConfigRequest, error) {
+	var req BusiConfigRequest
+	if err := remarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid busi config params: %v", err)
+	}
+	if len(req.Extra) > 0 {
+		if req.ExtendInfos == nil {
+			req.ExtendInfos = make(map[string]interface{}, len(req.Extra))
+		}
+		for k, v := range req.Extra {
+			req.ExtendInfos[k] = rawValue(v)
+		}
+		req.Extra = nil
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// BusiConfigResponse /v2/merchant/busi/config 响应模型
+type BusiConfigResponse struct {
+	RespCode string `json:"resp_code"`
+	RespDesc string `json:"resp_desc,omitempty"`
+	HuifuID  string `json:"huifu_id,omitempty"`
+	ReqSeqID string `json:"req_seq_id,omitempty"`
+	ReqDate  string `json:"req_date,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type busiConfigResponseAlias BusiConfigResponse
+
+func (r *BusiConfigResponse) UnmarshalJSON(data []byte) error {
+	var alias busiConfigResponseAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*r = BusiConfigResponse(alias)
+	r.Extra = extra
+	return nil
+}
+
+func (r BusiConfigResponse) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(busiConfigResponseAlias(r), r.Extra)
+}
+
+// BusiConfigQueryRequest /v2/merchant/busi/config/query 请求模型
+type BusiConfigQueryRequest struct {
+	ReqSeqID string `json:"req_seq_id,omitempty"`
+	ReqDate  string `json:"req_date,omitempty"`
+	HuifuID  string `json:"huifu_id"`
+
+	// Extra 保留模型未声明的字段，仅用于JSON往返无损，不随请求发往汇付
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type busiConfigQueryRequestAlias BusiConfigQueryRequest
+
+// NewBusiConfigQueryRequest 创建并校验微信配置查询请求
+func NewBusiConfigQueryRequest(huifuID string) (*BusiConfigQueryRequest, error) {
+	req := &BusiConfigQueryRequest{HuifuID: huifuID}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (r *BusiConfigQueryRequest) UnmarshalJSON(data []byte) error {
+	var alias busiConfigQueryRequestAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*r = BusiConfigQueryRequest(alias)
+	r.Extra = extra
+	return nil
+}
+
+func (r BusiConfigQueryRequest) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(busiConfigQueryRequestAlias(r), r.Extra)
+}
+
+// Validate 校验微信配置查询请求
+func (r *BusiConfigQueryRequest) Validate() error {
+	if err := validateHuifuID(r.HuifuID); err != nil {
+		return err
+	}
+	return validateReqSeq(r.ReqSeqID, r.ReqDate)
+}
+
+// ToParams 转换为 HuifuClient.CallAPI 使用的参数，未声明的字段不发送
+func (r *BusiConfigQueryRequest) ToParams() map[string]interface{} {
+	params := make(map[string]interface{})
+	params["huifu_id"] = r.HuifuID
+	setIfNotEmpty(params, "req_seq_id", r.ReqSeqID)
+	setIfNotEmpty(params, "req_date", r.ReqDate)
+	return params
+}
+
+// BusiConfigQueryRequestFromParams 从CallAPI参数解析并校验查询请求
+func BusiConfigQueryRequestFromParams(params map[string]interface{}) (*BusiConfigQueryRequest, error) {
+	var req BusiConfigQueryRequest
+	if err := remarshal(params, &req); err != nil {
+		return nil, fmt.Errorf("invalid busi config query params: %v", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// BusiConfigQueryResponse /v2/merchant/busi/config/query 响应模型
+type BusiConfigQueryResponse struct {
+	RespCode   string            `json:"resp_code"`
+	RespDesc   string            `json:"resp_desc,omitempty"`
+	HuifuID    string            `json:"huifu_id,omitempty"`
+	ReqSeqID   string            `json:"req_seq_id,omitempty"`
+	ReqDate    string            `json:"req_date,omitempty"`
+	WxConfList *WeChatConfigList `json:"wx_conf_list,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type busiConfigQueryResponseAlias BusiConfigQueryResponse
+
+func (r *BusiConfigQueryResponse) UnmarshalJSON(data []byte) error {
+	var alias busiConfigQueryResponseAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*r = BusiConfigQueryResponse(alias)
+	r.Extra = extra
+	return nil
+}
+
+func (r BusiConfigQueryResponse) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(busiConfigQueryResponseAlias(r), r.Extra)
+}
+
+// MerchantBasicInfo /v2/merchant/basicdata/query 响应中的商户关键信息
+type MerchantBasicInfo struct {
+	RespCode     string `json:"resp_code"`
+	RespDesc     string `json:"resp_desc,omitempty"`
+	HuifuID      string `json:"huifu_id,omitempty"`
+	RegName      string `json:"reg_name,omitempty"`       // 商户注册名称
+	ShortName    string `json:"short_name,omitempty"`     // 商户简称
+	EntType      string `json:"ent_type,omitempty"`       // 商户类型
+	UpperHuifuID string `json:"upper_huifu_id,omitempty"` // 上级渠道商户号
+	Status       string `json:"status,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type merchantBasicInfoAlias MerchantBasicInfo
+
+func (r *MerchantBasicInfo) UnmarshalJSON(data []byte) error {
+	var alias merchantBasicInfoAlias
+	extra, err := decodeWithExtra(data, &alias)
+	if err != nil {
+		return err
+	}
+	*r = MerchantBasicInfo(alias)
+	r.Extra = extra
+	return nil
+}
+
+func (r MerchantBasicInfo) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(merchantBasicInfoAlias(r), r.Extra)
+}
+
+// responseData 取出汇付响应中的业务数据，兼容带data包裹与平铺两种结构
+func responseData(result map[string]interface{}) map[string]interface{} {
+	if data, ok := result["data"].(map[string]interface{}); ok {
+		return data
+	}
+	return result
+}
+
+// ResponseDecodeError 汇付响应无法解析为响应模型
+type ResponseDecodeError struct {
+	Err error
+}
+
+func (e *ResponseDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response: %v", e.Err)
+}
+
+func (e *ResponseDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeResponse 将CallAPI结果解析为指定的响应模型
+// data 存在但不是JSON对象（如网关返回的HTML）时解析失败
+func DecodeResponse(result map[string]interface{}, out interface{}) error {
+	if data, present := result["data"]; present {
+		if _, ok := data.(map[string]interface{}); !ok {
+			return &ResponseDecodeError{Err: fmt.Errorf("data is not a JSON object: %.100v", data)}
+		}
+	}
+	if err := remarshal(responseData(result), out); err != nil {
+		return &ResponseDecodeError{Err: err}
+	}
+	return nil
+}
+
+// validateWithSysID 校验带sys_id的业务请求
+func validateWithSysID(sysID string, req interface{ Validate() error }) error {
+	if strings.TrimSpace(sysID) == "" {
+		return &ValidationError{Field: "sys_id", Reason: "is required"}
+	}
+	return req.Validate()
+}
+
+func validateHuifuID(huifuID string) error {
+	if huifuID == "" {
+		return &ValidationError{Field: "huifu_id", Reason: "is required"}
+	}
+	if !huifuIDPattern.MatchString(huifuID) {
+		return &ValidationError{Field: "huifu_id", Reason: "must contain only digits"}
+	}
+	return nil
+}
+
+func validateReqSeq(reqSeqID, reqDate string) error {
+	if reqSeqID != "" && !reqSeqIDPattern.MatchString(reqSeqID) {
+		return &ValidationError{Field: "req_seq_id", Reason: "must be 1-128 alphanumeric characters"}
+	}
+	if reqDate != "" {
+		if _, err := time.Parse("20060102", reqDate); err != nil {
+			return &ValidationError{Field: "req_date", Reason: "must be in yyyyMMdd format"}
+		}
+	}
+	return nil
+}
+
+func setIfNotEmpty(params map[string]interface{}, key, value string) {
+	if value != "" {
+		params[key] = value
+	}
+}
+
+// rawValue 将未声明字段的原始JSON还原为通用值，数字保留为json.Number
+func rawValue(raw json.RawMessage) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+// remarshal 通过JSON在两种表示之间转换
+func remarshal(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// decodeWithExtra 解码到alias结构体，并收集未声明的字段
+// 数字以json.Number保留，避免精度损失
+func decodeWithExtra(data []byte, alias interface{}) (map[string]json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(alias); err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	known := jsonFieldNames(reflect.TypeOf(alias).Elem())
+	var extra map[string]json.RawMessage
+	for name, raw := range fields {
+		if known[name] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[name] = raw
+	}
+	return extra, nil
+}
+
+// encodeWithExtra 编码alias结构体并合并未声明的字段
+func encodeWithExtra(alias interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(alias)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, raw := range extra {
+		if _, exists := fields[name]; !exists {
+			fields[name] = raw
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// jsonFieldNames 返回结构体声明的JSON字段名
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// volatileParams 每次调用都会变化的字段，回放匹配时忽略
+var volatileParams = map[string]bool{
+	"req_seq_id": true,
+	"req_date":   true,
+	"timestamp":  true,
+	"sign":       true,
+}
+
+// Interaction 一次录制的汇付调用
+type Interaction struct {
+	Endpoint   string                 `json:"endpoint"`
+	Params     map[string]interface{} `json:"params"`
+	Response   map[string]interface{} `json:"response,omitempty"`
+	Error      *RecordedError         `json:"error,omitempty"`
+	RecordedAt time.Time              `json:"recorded_at"`
+}
+
+// RecordedError 录制到的调用错误
+type RecordedError struct {
+	Category ErrorCategory `json:"category"`
+	Message  string        `json:"message"`
+}
+
+// Cassette 一个sys_id的录制文件
+type Cassette struct {
+	mu           sync.Mutex
+	path         string
+	SysID        string         `json:"sys_id"`
+	Interactions []*Interaction `json:"interactions"`
+
+	// 回放时相同请求按录制顺序依次返回，用完后重复最后一条
+	served map[string]int
+}
+
+// NoRecordingError 回放时请求没有对应的录制
+type NoRecordingError struct {
+	SysID    string
+	Endpoint string
+	Params   string
+}
+
+func (e *NoRecordingError) Error() string {
+	return fmt.Sprintf("no recording for sys_id %s %s with params %s", e.SysID, e.Endpoint, e.Params)
+}
+
+// cassetteNamePattern 录制文件名允许的sys_id，防止路径穿越
+var cassetteNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// cassettePath 录制文件路径：<dir>/<sys_id>.json
+func cassettePath(dir, sysID string) (string, error) {
+	if !cassetteNamePattern.MatchString(sysID) {
+		return "", &ValidationError{Field: "sys_id", Reason: "must contain only letters, digits, '_' or '-' to be used as a cassette name"}
+	}
+	return filepath.Join(dir, sysID+".json"), nil
+}
+
+// LoadCassette 读取录制文件，文件不存在时返回空录制
+func LoadCassette(path, sysID string) (*Cassette, error) {
+	cassette := &Cassette{path: path, SysID: sysID, served: make(map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cassette, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %v", path, err)
+	}
+	return cassette, nil
+}
+
+// Record 追加一条脱敏后的调用记录并写回文件
+func (c *Cassette) Record(endpoint string, params, response map[string]interface{}, callErr error) error {
+	interaction := &Interaction{
+		Endpoint:   endpoint,
+		Params:     scrubParams(params),
+		RecordedAt: time.Now(),
+	}
+	if response != nil {
+		interaction.Response = redactParams(response)
+	}
+	if callErr != nil {
+		interaction.Error = &RecordedError{
+			Category: AsHuifuError(endpoint, callErr).Category,
+			Message:  callErr.Error(),
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, interaction)
+	return c.saveLocked()
+}
+
+// saveLocked 写入临时文件后替换，避免中断时留下半个文件
+func (c *Cassette) saveLocked() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %v", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %v", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cassette: %v", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Find 查找与请求匹配的录制
+func (c *Cassette) Find(endpoint string, params map[string]interface{}) (*Interaction, error) {
+	key := matchKey(params)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*Interaction
+	for _, interaction := range c.Interactions {
+		if interaction.Endpoint == endpoint && matchKey(interaction.Params) == key {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, &NoRecordingError{SysID: c.SysID, Endpoint: endpoint, Params: key}
+	}
+
+	served := endpoint + " " + key
+	index := c.served[served]
+	if index >= len(matches) {
+		index = len(matches) - 1
+	}
+	c.served[served] = index + 1
+	return matches[index], nil
+}
+
+// scrubParams 去除易变字段并对敏感字段脱敏
+func scrubParams(params map[string]interface{}) map[string]interface{} {
+	stable := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if !volatileParams[k] {
+			stable[k] = v
+		}
+	}
+	return redactParams(stable)
+}
+
+// matchKey 参数的规范化形式：去除易变字段、脱敏后按key排序的JSON
+func matchKey(params map[string]interface{}) string {
+	data, err := json.Marshal(scrubParams(params))
+	if err != nil {
+		return fmt.Sprint(params)
+	}
+	return string(data)
+}
+
+// RecordingClient 将真实调用录制到cassette，调用结果原样返回
+type RecordingClient struct {
+	next     HuifuClient
+	cassette *Cassette
+}
+
+// NewRecordingClient 创建录制客户端
+func NewRecordingClient(next HuifuClient, cassette *Cassette) *RecordingClient {
+	return &RecordingClient{next: next, cassette: cassette}
+}
+
+// Unwrap 返回被包装的客户端
+func (c *RecordingClient) Unwrap() HuifuClient {
+	return c.next
+}
+
+// CallAPI 调用并录制
+func (c *RecordingClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	result, err := c.next.CallAPI(ctx, endpoint, params)
+	if recordErr := c.cassette.Record(endpoint, params, result, err); recordErr != nil {
+		log.Printf("Failed to record %s for sys_id=%s: %v", endpoint, c.cassette.SysID, recordErr)
+	}
+	return result, err
+}
+
+// ReplayHuifuClient 从cassette回放录制的调用，不访问网络
+type ReplayHuifuClient struct {
+	cassette *Cassette
+}
+
+// NewReplayHuifuClient 创建回放客户端
+func NewReplayHuifuClient(cassette *Cassette) *ReplayHuifuClient {
+	return &ReplayHuifuClient{cassette: cassette}
+}
+
+// CallAPI 返回匹配的录制结果，没有录制时返回 replay_miss 分类的 *HuifuError（包装 *NoRecordingError）
+func (c *ReplayHuifuClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	spec, err := endpointRegistry.Lookup(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.ValidateParams(params); err != nil {
+		return nil, err
+	}
+
+	interaction, err := c.cassette.Find(endpoint, params)
+	if err != nil {
+		log.Printf("Replay miss: %v", err)
+		return nil, &HuifuError{Category: CategoryReplayMiss, Endpoint: endpoint, Err: err}
+	}
+
+	if interaction.Error != nil {
+		return nil, &HuifuError{
+			Category: interaction.Error.Category,
+			Endpoint: endpoint,
+			Err:      fmt.Errorf("replayed: %s", interaction.Error.Message),
+		}
+	}
+	return replayResponse(interaction.Response, params), nil
+}
+
+// replayResponse 复制录制的响应，并将其中的流水号替换为本次请求的值
+func replayResponse(recorded, params map[string]interface{}) map[string]interface{} {
+	response := copyMap(recorded)
+	for _, target := range []map[string]interface{}{response, responseData(response)} {
+		for _, key := range []string{"req_seq_id", "req_date"} {
+			if _, present := target[key]; present && params[key] != nil {
+				target[key] = params[key]
+			}
+		}
+	}
+	return response
+}
+
+// newRecordingOrReplayClient 按环境变量包装或替换客户端
+//
+//	HUIFU_REPLAY_DIR  演示/测试模式：使用 <dir>/<sys_id>.json 回放，不访问汇付
+//	HUIFU_RECORD_DIR  录制真实客户端的调用到 <dir>/<sys_id>.json
+func newRecordingOrReplayClient(sysID string, client HuifuClient) (HuifuClient, error) {
+	if dir := os.Getenv("HUIFU_REPLAY_DIR"); dir != "" {
+		path, err := cassettePath(dir, sysID)
+		if err != nil {
+			return nil, err
+		}
+		cassette, err := LoadCassette(path, sysID)
+		if err != nil {
+			return nil, err
+		}
+		if len(cassette.Interactions) == 0 {
+			log.Printf("No recordings for sys_id=%s in %s, every call will fail", sysID, dir)
+		}
+		// 回放模式不使用真实客户端，清理其临时配置文件或签名器中的私钥
+		if cleaner, ok := client.(interface{ Cleanup() }); ok {
+			cleaner.Cleanup()
+		}
+		return NewReplayHuifuClient(cassette), nil
+	}
+
+	if dir := os.Getenv("HUIFU_RECORD_DIR"); dir != "" {
+		switch client.(type) {
+		case *RealHuifuClient, *SignerHuifuClient:
+			path, err := cassettePath(dir, sysID)
+			if err != nil {
+				return nil, err
+			}
+			cassette, err := LoadCassette(path, sysID)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Recording Huifu calls for sys_id=%s to %s", sysID, cassette.path)
+			return NewRecordingClient(client, cassette), nil
+		}
+	}
+	return client, nil
+}
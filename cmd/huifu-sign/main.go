@@ -0,0 +1,109 @@
+// huifu-sign 汇付v2签名调试工具
+//
+// 用法：
+//
+//	huifu-sign canonical [data.json]                          输出data的签名原文
+//	huifu-sign sign -key merchant.pem [data.json]             对data签名
+//	huifu-sign verify -pubkey huifu.pub -sign SIGN [data.json] 校验响应data的签名
+//	huifu-sign notify -pubkey huifu.pub -sign SIGN [resp_data] 校验异步通知resp_data的签名
+//	huifu-sign vectors                                        用附带的测试向量自检
+//
+// data 可以是JSON对象，也可以是JSON字符串；省略文件时从标准输入读取。
+// -pubkey 省略时使用环境变量 HUIFU_PUBLIC_KEY。
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"huifu-config-system/huifusign"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	keyPath := flags.String("key", "", "PEM file with the merchant private key")
+	pubKey := flags.String("pubkey", "", "file with the Huifu public key (defaults to HUIFU_PUBLIC_KEY)")
+	sign := flags.String("sign", "", "base64 signature to verify")
+	flags.Parse(args)
+
+	switch command {
+	case "canonical":
+		content, _, err := huifusign.ParseData(readInput(flags))
+		exitOnError("Failed to canonicalize data", err)
+		fmt.Println(string(content))
+	case "sign":
+		key, err := huifusign.ParsePrivateKey(readFile(*keyPath))
+		exitOnError("Failed to parse private key", err)
+		content, _, err := huifusign.ParseData(readInput(flags))
+		exitOnError("Failed to canonicalize data", err)
+		signature, err := huifusign.Sign(key, content)
+		exitOnError("Failed to sign", err)
+		fmt.Println(signature)
+	case "verify":
+		key := loadPublicKey(*pubKey)
+		exitOnError("Verification failed", huifusign.VerifyData(key, readInput(flags), *sign))
+		fmt.Println("OK")
+	case "notify":
+		key := loadPublicKey(*pubKey)
+		exitOnError("Verification failed", huifusign.VerifyNotification(key, strings.TrimRight(string(readInput(flags)), "\r\n"), *sign))
+		fmt.Println("OK")
+	case "vectors":
+		exitOnError("Test vectors failed", huifusign.CheckVectors())
+		set, err := huifusign.Vectors()
+		exitOnError("Failed to load test vectors", err)
+		fmt.Printf("OK: %d vectors\n", len(set.Vectors))
+	default:
+		usage()
+	}
+}
+
+// readInput 读取参数指定的文件或标准输入
+func readInput(flags *flag.FlagSet) json.RawMessage {
+	if flags.NArg() > 0 {
+		return json.RawMessage(readFile(flags.Arg(0)))
+	}
+	data, err := io.ReadAll(os.Stdin)
+	exitOnError("Failed to read stdin", err)
+	return json.RawMessage(data)
+}
+
+func readFile(path string) string {
+	if path == "" {
+		exitOnError("Missing argument", fmt.Errorf("a file path is required"))
+	}
+	data, err := os.ReadFile(path)
+	exitOnError("Failed to read "+path, err)
+	return string(data)
+}
+
+func loadPublicKey(path string) *rsa.PublicKey {
+	encoded := os.Getenv("HUIFU_PUBLIC_KEY")
+	if path != "" {
+		encoded = readFile(path)
+	}
+	key, err := huifusign.ParsePublicKey(encoded)
+	exitOnError("Failed to parse public key", err)
+	return key
+}
+
+func exitOnError(message string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", message, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: huifu-sign canonical|sign|verify|notify|vectors [flags] [file]")
+	os.Exit(2)
+}
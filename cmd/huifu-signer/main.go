@@ -0,0 +1,100 @@
+// huifu-signer 签名守护进程，持有商户私钥并经Unix socket为Web服务签名
+//
+// 用法：
+//
+//	huifu-signer -socket /run/huifu/signer.sock -keys keys.json -allow-uid 1000
+//
+// keys.json 为 {"<sys_id>": "<PEM私钥>"}，也可由Web服务在保存配置时导入（-import=false 禁用）。
+// 预置私钥的sys_id保存配置时可不提交私钥，Web服务经 pubkey 操作取得公钥。
+// 仅 -allow-uid 列出的uid（默认为本进程uid）可以连接。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"huifu-config-system/huifusign"
+	"huifu-config-system/signerd"
+)
+
+func main() {
+	socketPath := flag.String("socket", envOr("HUIFU_SIGNER_SOCKET", "./huifu-signer.sock"), "Unix socket path")
+	keysPath := flag.String("keys", os.Getenv("HUIFU_SIGNER_KEYS"), "JSON file mapping sys_id to PEM private key")
+	allowUIDs := flag.String("allow-uid", strconv.Itoa(os.Getuid()), "comma-separated uids allowed to connect")
+	allowImport := flag.Bool("import", true, "accept key import and removal from clients")
+	flag.Parse()
+
+	huifuKey, err := huifusign.ParsePublicKey(envOr("HUIFU_PUBLIC_KEY", huifusign.HuifuPublicKey))
+	if err != nil {
+		log.Fatal("Failed to parse Huifu public key:", err)
+	}
+
+	server := signerd.NewServer(huifuKey, parseUIDs(*allowUIDs), *allowImport)
+	if *keysPath != "" {
+		loadKeys(server, *keysPath)
+	}
+
+	listener, err := signerd.Listen(*socketPath)
+	if err != nil {
+		log.Fatal("Failed to listen:", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	log.Printf("Huifu signer listening on %s (allowed uids: %s)", *socketPath, *allowUIDs)
+	if err := server.Serve(listener); err != nil {
+		log.Fatal("Signer stopped:", err)
+	}
+	log.Println("Huifu signer stopped")
+}
+
+func loadKeys(server *signerd.Server, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Failed to read keys file:", err)
+	}
+	var keys map[string]string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		log.Fatal("Failed to parse keys file:", err)
+	}
+	for sysID, key := range keys {
+		if err := server.AddKey(sysID, key); err != nil {
+			log.Fatalf("Failed to load key for sys_id=%s: %v", sysID, err)
+		}
+		log.Printf("Loaded key for sys_id=%s", sysID)
+	}
+}
+
+func parseUIDs(spec string) []uint32 {
+	var uids []uint32
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		uid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			log.Fatalf("Invalid uid %q: %v", field, err)
+		}
+		uids = append(uids, uint32(uid))
+	}
+	return uids
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
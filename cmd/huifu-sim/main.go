@@ -0,0 +1,82 @@
+// huifu-sim 本地汇付v2接口模拟服务
+//
+// 用法：
+//
+//	huifu-sim -addr :40005 -key huifu_private.pem -merchants merchants.json
+//
+// merchants.json 为商户列表：[{"sys_id": "...", "product_id": "...", "public_key": "..."}]，
+// 也可运行时通过 POST /sim/merchants 登记。启动日志输出的汇付公钥需配置到被测服务的 HUIFU_PUBLIC_KEY。
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"huifu-config-system/huifusign"
+	"huifu-config-system/sim"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("HUIFU_SIM_ADDR", ":40005"), "listen address")
+	keyPath := flag.String("key", os.Getenv("HUIFU_SIM_KEY"), "PEM file with the simulator's Huifu private key (generated when empty)")
+	merchantsPath := flag.String("merchants", os.Getenv("HUIFU_SIM_MERCHANTS"), "JSON file listing merchants and their public keys")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+
+	server, err := sim.New(loadKey(*keyPath))
+	if err != nil {
+		log.Fatal("Failed to create simulator:", err)
+	}
+
+	if *merchantsPath != "" {
+		data, err := os.ReadFile(*merchantsPath)
+		if err != nil {
+			log.Fatal("Failed to read merchants file:", err)
+		}
+		var merchants []sim.Merchant
+		if err := json.Unmarshal(data, &merchants); err != nil {
+			log.Fatal("Failed to parse merchants file:", err)
+		}
+		for _, merchant := range merchants {
+			if err := server.AddMerchant(merchant.SysID, merchant.ProductID, merchant.PublicKey); err != nil {
+				log.Fatal("Failed to register merchant:", err)
+			}
+			log.Printf("Registered merchant sys_id=%s product_id=%s", merchant.SysID, merchant.ProductID)
+		}
+	}
+
+	log.Printf("Huifu public key (set as HUIFU_PUBLIC_KEY): %s", server.HuifuPublicKey())
+	log.Println("Huifu simulator listening on " + *addr + "...")
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatal("Failed to start simulator:", err)
+	}
+}
+
+func loadKey(path string) *rsa.PrivateKey {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Failed to read key file:", err)
+	}
+	key, err := huifusign.ParsePrivateKey(string(data))
+	if err != nil {
+		log.Fatal("Failed to parse key file:", err)
+	}
+	return key
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
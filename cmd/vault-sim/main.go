@@ -0,0 +1,68 @@
+// vault-sim 本地Vault KV v2模拟服务，用于开发环境测试 vault:// 私钥引用
+//
+// 用法：
+//
+//	vault-sim -addr :8200 -token root -token-ttl 1h -seed secrets.json
+//
+// secrets.json 为 {"<mount>/<path>": {"<field>": "<value>"}}，也可运行时通过
+// PUT /v1/<mount>/data/<path> 写入。被测服务需设置 VAULT_ADDR 和 VAULT_TOKEN。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"huifu-config-system/vaultsim"
+)
+
+func main() {
+	addr := flag.String("addr", envOr("VAULT_SIM_ADDR", ":8200"), "listen address")
+	token := flag.String("token", envOr("VAULT_TOKEN", "root"), "token required in X-Vault-Token (empty disables the check)")
+	tokenTTL := flag.Duration("token-ttl", 0, "token TTL, renewable via auth/token/renew-self (0 never expires)")
+	seedPath := flag.String("seed", os.Getenv("VAULT_SIM_SEED"), "JSON file mapping <mount>/<path> to secret data")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+
+	server := vaultsim.New(*token, *tokenTTL)
+	if *seedPath != "" {
+		seed(server, *seedPath)
+	}
+
+	log.Println("Vault simulator listening on " + *addr + "...")
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatal("Failed to start Vault simulator:", err)
+	}
+}
+
+func seed(server *vaultsim.Server, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Failed to read seed file:", err)
+	}
+	var secrets map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		log.Fatal("Failed to parse seed file:", err)
+	}
+	for key, values := range secrets {
+		mount, secretPath, ok := strings.Cut(strings.Trim(key, "/"), "/")
+		if !ok || secretPath == "" {
+			log.Fatalf("Invalid secret key %q, expected <mount>/<path>", key)
+		}
+		server.Put(mount, secretPath, values)
+		log.Printf("Seeded secret %s", key)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
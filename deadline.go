@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CallPhase 一次汇付调用所处的阶段
+type CallPhase string
+
+const (
+	PhaseSigning   CallPhase = "signing"
+	PhaseTransport CallPhase = "transport"
+	PhaseDecoding  CallPhase = "decoding"
+)
+
+// defaultCallTimeout 未单独配置的接口使用的默认超时
+const defaultCallTimeout = 15 * time.Second
+
+// endpointTimeout 返回接口的默认超时，取自接口注册表
+func endpointTimeout(endpoint string) time.Duration {
+	if spec, err := endpointRegistry.Lookup(endpoint); err == nil {
+		return spec.Timeout
+	}
+	return defaultCallTimeout
+}
+
+// withEndpointDeadline 为调用附加接口默认超时
+// 若上游context已有更早的截止时间，则以上游为准
+func withEndpointDeadline(ctx context.Context, endpoint string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, endpointTimeout(endpoint))
+}
+
+// CallTimeoutError 调用超时或被取消时返回的错误，记录超时发生的阶段
+type CallTimeoutError struct {
+	Endpoint string
+	Phase    CallPhase
+	Elapsed  time.Duration
+	Err      error // context.DeadlineExceeded 或 context.Canceled
+	// OutcomeUnknown 请求已发出但未等到结果，汇付可能仍会受理；写操作需经状态查询确认后再重试
+	OutcomeUnknown bool
+}
+
+func (e *CallTimeoutError) Error() string {
+	reason := "timed out"
+	if errors.Is(e.Err, context.Canceled) {
+		reason = "canceled"
+	}
+	msg := fmt.Sprintf("call to %s %s during %s phase after %v", e.Endpoint, reason, e.Phase, e.Elapsed.Round(time.Millisecond))
+	if e.OutcomeUnknown {
+		msg += "; outcome unknown, the request may still be applied by Huifu"
+	}
+	return msg
+}
+
+func (e *CallTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// checkPhase 在进入某阶段前检查context是否已结束
+func checkPhase(ctx context.Context, endpoint string, phase CallPhase, start time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return &CallTimeoutError{Endpoint: endpoint, Phase: phase, Elapsed: time.Since(start), Err: err}
+	}
+	return nil
+}
+
+// runPhase 在context约束下执行一个不支持取消的阶段（如SDK阻塞调用）
+// context结束时立即返回超时错误，后台goroutine的结果将被丢弃；
+// 传输阶段被放弃时请求可能仍会完成，错误标记为结果未知
+func runPhase[T any](ctx context.Context, endpoint string, phase CallPhase, start time.Time, fn func() (T, error)) (T, error) {
+	type outcome struct {
+		value T
+		err   error
+	}
+
+	var zero T
+	if err := checkPhase(ctx, endpoint, phase, start); err != nil {
+		return zero, err
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		value, err := fn()
+		done <- outcome{value: value, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		return zero, &CallTimeoutError{
+			Endpoint:       endpoint,
+			Phase:          phase,
+			Elapsed:        time.Since(start),
+			Err:            ctx.Err(),
+			OutcomeUnknown: phase == PhaseTransport,
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huifurepo/bspay-go-sdk/BsPaySdk"
+	"github.com/huifurepo/bspay-go-sdk/ut/tool"
+)
+
+// ParamType 参数类型
+type ParamType string
+
+const (
+	ParamString ParamType = "string"
+	ParamNumber ParamType = "number"
+	ParamBool   ParamType = "bool"
+	ParamObject ParamType = "object"
+	ParamArray  ParamType = "array"
+)
+
+// ParamSpec 单个参数的描述
+type ParamSpec struct {
+	Name        string         `json:"name"`
+	Type        ParamType      `json:"type"`
+	Required    bool           `json:"required"`
+	Pattern     *regexp.Regexp `json:"-"`
+	Description string         `json:"description,omitempty"`
+}
+
+// SDKCallFunc 通过bspay SDK执行一次请求，params已通过参数校验
+type SDKCallFunc func(sdk *BsPaySdk.BsPay, params map[string]interface{}) (map[string]interface{}, error)
+
+// MockResponseFunc 生成模拟客户端的响应
+type MockResponseFunc func(c *MockHuifuClient, params map[string]interface{}) map[string]interface{}
+
+// EndpointSpec 一个汇付接口的完整描述
+type EndpointSpec struct {
+	Path        string        `json:"path"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Params      []ParamSpec   `json:"params"`
+	AllowExtra  bool          `json:"allow_extra"` // 是否允许schema之外的扩展参数
+	Idempotent  bool          `json:"idempotent"`
+	Timeout     time.Duration `json:"timeout"`
+	// Permission 通用调用接口所需的权限，未设置时幂等接口为read，其余为write
+	Permission EndpointPermission `json:"permission"`
+
+	// SDKCall 真实客户端使用的SDK请求构建及调用，为空表示尚未接入SDK
+	SDKCall SDKCallFunc `json:"-"`
+	// MockResponse 模拟客户端的响应，为空时返回通用成功响应
+	MockResponse MockResponseFunc `json:"-"`
+	// StatusCheck 非幂等接口重试前用于确认上次调用是否已生效，为空时不重试
+	StatusCheck StatusCheckFunc `json:"-"`
+}
+
+// RequiredParams 返回必填参数名
+func (s *EndpointSpec) RequiredParams() []string {
+	var names []string
+	for _, p := range s.Params {
+		if p.Required {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// ValidateParams 按schema校验参数
+func (s *EndpointSpec) ValidateParams(params map[string]interface{}) error {
+	known := make(map[string]bool, len(s.Params))
+	for _, p := range s.Params {
+		known[p.Name] = true
+
+		value, exists := params[p.Name]
+		if !exists || value == nil || value == "" {
+			if p.Required {
+				return &ValidationError{Field: p.Name, Reason: "is required"}
+			}
+			continue
+		}
+		if !matchesParamType(value, p.Type) {
+			return &ValidationError{Field: p.Name, Reason: fmt.Sprintf("must be of type %s", p.Type)}
+		}
+		if p.Pattern != nil {
+			if str, ok := value.(string); ok && !p.Pattern.MatchString(str) {
+				return &ValidationError{Field: p.Name, Reason: fmt.Sprintf("must match %s", p.Pattern.String())}
+			}
+		}
+	}
+
+	if !s.AllowExtra {
+		for name := range params {
+			if !known[name] {
+				return &ValidationError{Field: name, Reason: fmt.Sprintf("is not accepted by %s", s.Path)}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesParamType 判断参数值是否符合声明的类型
+func matchesParamType(value interface{}, t ParamType) bool {
+	switch t {
+	case ParamString:
+		_, ok := value.(string)
+		return ok
+	case ParamNumber:
+		switch value.(type) {
+		case float64, float32, int, int64, int32, uint, uint64, uint32, interface{ Float64() (float64, error) }:
+			return true
+		}
+		return false
+	case ParamBool:
+		_, ok := value.(bool)
+		return ok
+	case ParamObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case ParamArray:
+		_, ok := value.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// EndpointRegistry 汇付接口注册表，真实、模拟及仿真客户端统一通过它分发
+type EndpointRegistry struct {
+	mu        sync.RWMutex
+	endpoints map[string]*EndpointSpec
+}
+
+// NewEndpointRegistry 创建接口注册表
+func NewEndpointRegistry() *EndpointRegistry {
+	return &EndpointRegistry{
+		endpoints: make(map[string]*EndpointSpec),
+	}
+}
+
+// Register 注册接口，重复注册同一路径会panic
+func (r *EndpointRegistry) Register(spec EndpointSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.endpoints[spec.Path]; exists {
+		panic(fmt.Sprintf("endpoint already registered: %s", spec.Path))
+	}
+	if spec.Timeout == 0 {
+		spec.Timeout = defaultCallTimeout
+	}
+	if spec.Permission == "" {
+		spec.Permission = PermissionWrite
+		if spec.Idempotent {
+			spec.Permission = PermissionRead
+		}
+	}
+	r.endpoints[spec.Path] = &spec
+}
+
+// Lookup 查找接口描述
+func (r *EndpointRegistry) Lookup(path string) (*EndpointSpec, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spec, exists := r.endpoints[path]
+	if !exists {
+		return nil, fmt.Errorf("unsupported endpoint: %s", path)
+	}
+	return spec, nil
+}
+
+// List 按路径排序返回全部接口
+func (r *EndpointRegistry) List() []*EndpointSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	specs := make([]*EndpointSpec, 0, len(r.endpoints))
+	for _, spec := range r.endpoints {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Path < specs[j].Path
+	})
+	return specs
+}
+
+var endpointRegistry = NewEndpointRegistry()
+
+// reqSeqParams 所有接口共有的流水号参数
+var reqSeqParams = []ParamSpec{
+	{Name: "req_seq_id", Type: ParamString, Pattern: reqSeqIDPattern, Description: "请求流水号"},
+	{Name: "req_date", Type: ParamString, Pattern: regexp.MustCompile(`^[0-9]{8}$`), Description: "请求日期 yyyyMMdd"},
+}
+
+func init() {
+	endpointRegistry.Register(EndpointSpec{
+		Path:        "/v2/merchant/busi/config",
+		Name:        "微信配置",
+		Description: "配置商户的微信公众号/小程序AppID及授权目录",
+		Params: append([]ParamSpec{
+			{Name: "huifu_id", Type: ParamString, Required: true, Pattern: huifuIDPattern, Description: "汇付商户号"},
+			{Name: "fee_type", Type: ParamString, Required: true, Pattern: feeTypePattern, Description: "费率类型"},
+			{Name: "wx_woa_app_id", Type: ParamString, Pattern: wxAppIDPattern, Description: "公众号AppID"},
+			{Name: "wx_woa_path", Type: ParamString, Description: "公众号授权目录"},
+			{Name: "wx_applet_app_id", Type: ParamString, Pattern: wxAppIDPattern, Description: "小程序AppID"},
+			{Name: "wx_subscribe_app_id", Type: ParamString, Pattern: wxAppIDPattern, Description: "推荐关注AppID"},
+		}, reqSeqParams...),
+		AllowExtra:   true,
+		Idempotent:   false,
+		Timeout:      30 * time.Second,
+		SDKCall:      sdkBusiConfig,
+		MockResponse: mockBusiConfig,
+		StatusCheck:  busiConfigStatus,
+	})
+
+	endpointRegistry.Register(EndpointSpec{
+		Path:        "/v2/merchant/busi/config/query",
+		Name:        "微信配置查询",
+		Description: "查询商户已配置的微信AppID列表",
+		Params: append([]ParamSpec{
+			{Name: "huifu_id", Type: ParamString, Required: true, Pattern: huifuIDPattern, Description: "汇付商户号"},
+		}, reqSeqParams...),
+		Idempotent:   true,
+		Timeout:      10 * time.Second,
+		SDKCall:      sdkBusiConfigQuery,
+		MockResponse: mockBusiConfigQuery,
+	})
+
+	endpointRegistry.Register(EndpointSpec{
+		Path:        "/v2/merchant/basicdata/query",
+		Name:        "商户基本信息查询",
+		Description: "查询商户基本信息，用于验证配置连通性",
+		Params: append([]ParamSpec{
+			{Name: "huifu_id", Type: ParamString, Pattern: huifuIDPattern, Description: "汇付商户号"},
+		}, reqSeqParams...),
+		AllowExtra:   true,
+		Idempotent:   true,
+		Timeout:      10 * time.Second,
+		SDKCall:      sdkBasicdataQuery,
+		MockResponse: mockBasicdataQuery,
+	})
+}
+
+// reqSeqFromParams 取调用方（或重试层）给定的流水号，未提供时由SDK生成
+func reqSeqFromParams(params map[string]interface{}) (string, string) {
+	reqSeqID, _ := params["req_seq_id"].(string)
+	reqDate, _ := params["req_date"].(string)
+	if reqSeqID == "" {
+		reqSeqID = tool.GetReqSeqId()
+	}
+	if reqDate == "" {
+		reqDate = tool.GetCurrentDate()
+	}
+	return reqSeqID, reqDate
+}
+
+// sdkBusiConfig 微信配置SDK调用
+func sdkBusiConfig(sdk *BsPaySdk.BsPay, params map[string]interface{}) (map[string]interface{}, error) {
+	busiReq, err := BusiConfigRequestFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 业务字段通过扩展信息传递
+	reqSeqID, reqDate := reqSeqFromParams(params)
+	req := BsPaySdk.V2MerchantBusiConfigRequest{
+		ReqSeqId:    reqSeqID,
+		ReqDate:     reqDate,
+		HuifuId:     busiReq.HuifuID,
+		ExtendInfos: busiReq.SDKExtendInfos(),
+	}
+
+	result, err := sdk.V2MerchantBusiConfigRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("WeChat config SDK call failed: %v", err)
+	}
+	return result, nil
+}
+
+// sdkBusiConfigQuery 微信配置查询SDK调用
+func sdkBusiConfigQuery(sdk *BsPaySdk.BsPay, params map[string]interface{}) (map[string]interface{}, error) {
+	queryReq, err := BusiConfigQueryRequestFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	reqSeqID, reqDate := reqSeqFromParams(params)
+	req := BsPaySdk.V2MerchantBusiConfigQueryRequest{
+		ReqSeqId: reqSeqID,
+		ReqDate:  reqDate,
+		HuifuId:  queryReq.HuifuID,
+	}
+
+	result, err := sdk.V2MerchantBusiConfigQueryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("merchant query SDK call failed: %v", err)
+	}
+	return result, nil
+}
+
+// sdkBasicdataQuery 商户基本信息查询SDK调用，未指定huifu_id时查询sys_id本身
+func sdkBasicdataQuery(sdk *BsPaySdk.BsPay, params map[string]interface{}) (map[string]interface{}, error) {
+	huifuID := firstNonEmpty(stringField(params, "huifu_id"), stringField(params, "sys_id"))
+	if err := validateHuifuID(huifuID); err != nil {
+		return nil, err
+	}
+
+	extendInfos := make(map[string]interface{})
+	for k, v := range params {
+		switch k {
+		case "huifu_id", "sys_id", "req_seq_id", "req_date":
+		default:
+			extendInfos[k] = v
+		}
+	}
+
+	reqSeqID, reqDate := reqSeqFromParams(params)
+	req := BsPaySdk.V2MerchantBasicdataQueryRequest{
+		ReqSeqId:    reqSeqID,
+		ReqDate:     reqDate,
+		HuifuId:     huifuID,
+		ExtendInfos: extendInfos,
+	}
+
+	result, err := sdk.V2MerchantBasicdataQueryRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("merchant basic data SDK call failed: %v", err)
+	}
+	return result, nil
+}
+
+// listEndpoints 列出已注册的汇付接口
+func listEndpoints(c *gin.Context) {
+	specs := endpointRegistry.List()
+	endpoints := make([]gin.H, 0, len(specs))
+	for _, spec := range specs {
+		endpoints = append(endpoints, gin.H{
+			"path":            spec.Path,
+			"name":            spec.Name,
+			"description":     spec.Description,
+			"params":          spec.Params,
+			"required_params": spec.RequiredParams(),
+			"allow_extra":     spec.AllowExtra,
+			"idempotent":      spec.Idempotent,
+			"timeout":         spec.Timeout.String(),
+			"permission":      spec.Permission,
+			"callable":        passthroughPolicy.Allows(spec.Path),
+			"sdk_supported":   spec.SDKCall != nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints": endpoints,
+		"count":     len(endpoints),
+	})
+}
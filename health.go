@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthStatus 客户端健康状态
+type HealthStatus string
+
+const (
+	HealthUnknown   HealthStatus = "unknown"   // 尚未检查
+	HealthHealthy   HealthStatus = "healthy"   // 最近检查均成功
+	HealthDegraded  HealthStatus = "degraded"  // 最近检查中出现失败
+	HealthUnhealthy HealthStatus = "unhealthy" // 连续失败达到阈值
+)
+
+// healthActor 健康检查调用在日志中显示的操作者
+const healthActor = "health-checker"
+
+// HealthCheckResult 单次健康检查结果
+type HealthCheckResult struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Success   bool          `json:"success"`
+	Category  ErrorCategory `json:"category,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	LatencyMs int64         `json:"latency_ms"`
+}
+
+// HealthEvent 健康状态变化事件
+type HealthEvent struct {
+	SysID      string       `json:"sys_id"`
+	From       HealthStatus `json:"from"`
+	To         HealthStatus `json:"to"`
+	Reason     string       `json:"reason,omitempty"`
+	OccurredAt time.Time    `json:"occurred_at"`
+}
+
+// HealthConfig 健康检查参数
+type HealthConfig struct {
+	Interval         time.Duration // 检查间隔，<=0 时不启动定时检查
+	History          int           // 每个sys_id保留的检查结果数
+	FailureThreshold int           // 连续失败多少次判定为 unhealthy
+}
+
+// LoadHealthConfig 从环境变量 HUIFU_HEALTH_INTERVAL_SECONDS、HUIFU_HEALTH_HISTORY、HUIFU_HEALTH_FAILURES 加载配置
+func LoadHealthConfig() HealthConfig {
+	cfg := HealthConfig{
+		Interval:         5 * time.Minute,
+		History:          10,
+		FailureThreshold: 3,
+	}
+	if v := envInt("HUIFU_HEALTH_INTERVAL_SECONDS"); v != 0 {
+		cfg.Interval = time.Duration(v) * time.Second
+	}
+	if v := envInt("HUIFU_HEALTH_HISTORY"); v > 0 {
+		cfg.History = v
+	}
+	if v := envInt("HUIFU_HEALTH_FAILURES"); v > 0 {
+		cfg.FailureThreshold = v
+	}
+	return cfg
+}
+
+// HealthChecker 定期对每个已配置的客户端发起只读调用，记录结果并计算健康状态
+type HealthChecker struct {
+	mu        sync.RWMutex
+	config    HealthConfig
+	results   map[string][]HealthCheckResult
+	statuses  map[string]HealthStatus
+	epochs    map[string]uint64 // 每次 Forget 递增，用于丢弃删除前已开始的检查结果
+	events    []HealthEvent
+	maxEvents int
+	onChange  []func(HealthEvent)
+}
+
+// NewHealthChecker 创建健康检查器
+func NewHealthChecker(config HealthConfig) *HealthChecker {
+	return &HealthChecker{
+		config:    config,
+		results:   make(map[string][]HealthCheckResult),
+		statuses:  make(map[string]HealthStatus),
+		epochs:    make(map[string]uint64),
+		maxEvents: 1000,
+	}
+}
+
+// OnChange 注册健康状态变化回调
+func (h *HealthChecker) OnChange(fn func(HealthEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onChange = append(h.onChange, fn)
+}
+
+// Start 启动定时检查，ctx结束时停止
+func (h *HealthChecker) Start(ctx context.Context) {
+	if h.config.Interval <= 0 {
+		log.Println("Health checks disabled")
+		return
+	}
+	log.Printf("Health checks every %v", h.config.Interval)
+
+	go func() {
+		ticker := time.NewTicker(h.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.CheckAll(ctx)
+			}
+		}
+	}()
+}
+
+// CheckAll 依次检查所有已配置的sys_id
+func (h *HealthChecker) CheckAll(ctx context.Context) {
+	for _, sysID := range configManager.SysIDs() {
+		if ctx.Err() != nil {
+			return
+		}
+		client, err := configManager.GetProbeClient(sysID)
+		if err != nil {
+			// 检查期间配置已被删除
+			continue
+		}
+		h.Check(ctx, sysID, client)
+	}
+}
+
+// Check 对单个sys_id执行一次健康检查
+// 检查期间配置被删除时结果不记录，避免重新生成已删除sys_id的状态
+func (h *HealthChecker) Check(ctx context.Context, sysID string, client HuifuClient) HealthCheckResult {
+	h.mu.RLock()
+	epoch := h.epochs[sysID]
+	h.mu.RUnlock()
+
+	ctx = WithActor(ctx, healthActor)
+	start := time.Now()
+	if _, exists := configManager.GetConfig(sysID); !exists {
+		return HealthCheckResult{
+			CheckedAt: start,
+			Category:  CategoryInternal,
+			Error:     fmt.Sprintf("configuration not found for sys_id: %s", sysID),
+		}
+	}
+	_, err := checkConnectivity(ctx, client, sysID)
+
+	result := HealthCheckResult{
+		CheckedAt: start,
+		Success:   err == nil,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Category = AsHuifuError("", err).Category
+		result.Error = err.Error()
+	}
+	h.record(sysID, epoch, result)
+	return result
+}
+
+// Record 记录检查结果并在状态变化时发出事件
+func (h *HealthChecker) Record(sysID string, result HealthCheckResult) {
+	h.mu.RLock()
+	epoch := h.epochs[sysID]
+	h.mu.RUnlock()
+	h.record(sysID, epoch, result)
+}
+
+// record 记录检查结果，检查开始后sys_id已被 Forget 时丢弃
+func (h *HealthChecker) record(sysID string, epoch uint64, result HealthCheckResult) {
+	h.mu.Lock()
+	if h.epochs[sysID] != epoch {
+		h.mu.Unlock()
+		log.Printf("Discarding health check result for deleted sys_id=%s", sysID)
+		return
+	}
+	results := append(h.results[sysID], result)
+	if len(results) > h.config.History {
+		results = results[len(results)-h.config.History:]
+	}
+	h.results[sysID] = results
+
+	previous, ok := h.statuses[sysID]
+	if !ok {
+		previous = HealthUnknown
+	}
+	current := h.evaluate(results)
+	h.statuses[sysID] = current
+
+	if current == previous {
+		h.mu.Unlock()
+		return
+	}
+	event := HealthEvent{
+		SysID:      sysID,
+		From:       previous,
+		To:         current,
+		Reason:     result.Error,
+		OccurredAt: result.CheckedAt,
+	}
+	h.events = append(h.events, event)
+	if len(h.events) > h.maxEvents {
+		h.events = h.events[len(h.events)-h.maxEvents:]
+	}
+	callbacks := append([]func(HealthEvent){}, h.onChange...)
+	h.mu.Unlock()
+
+	log.Printf("Health changed: sys_id=%s %s -> %s %s", sysID, previous, current, event.Reason)
+	for _, fn := range callbacks {
+		fn(event)
+	}
+}
+
+// evaluate 根据最近的检查结果计算健康状态
+func (h *HealthChecker) evaluate(results []HealthCheckResult) HealthStatus {
+	if len(results) == 0 {
+		return HealthUnknown
+	}
+
+	consecutive := 0
+	for i := len(results) - 1; i >= 0 && !results[i].Success; i-- {
+		consecutive++
+	}
+	if consecutive >= h.config.FailureThreshold {
+		return HealthUnhealthy
+	}
+	for _, result := range results {
+		if !result.Success {
+			return HealthDegraded
+		}
+	}
+	return HealthHealthy
+}
+
+// Status 返回sys_id当前的健康状态
+func (h *HealthChecker) Status(sysID string) HealthStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if status, ok := h.statuses[sysID]; ok {
+		return status
+	}
+	return HealthUnknown
+}
+
+// Results 返回sys_id最近的检查结果（按时间倒序）
+func (h *HealthChecker) Results(sysID string) []HealthCheckResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stored := h.results[sysID]
+	results := make([]HealthCheckResult, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		results = append(results, stored[i])
+	}
+	return results
+}
+
+// Events 返回健康状态变化事件（按时间倒序），sysID为空时返回全部
+func (h *HealthChecker) Events(sysID string) []HealthEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	events := []HealthEvent{}
+	for i := len(h.events) - 1; i >= 0; i-- {
+		if sysID == "" || h.events[i].SysID == sysID {
+			events = append(events, h.events[i])
+		}
+	}
+	return events
+}
+
+// Forget 删除配置时清除其健康记录
+func (h *HealthChecker) Forget(sysID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.results, sysID)
+	delete(h.statuses, sysID)
+	h.epochs[sysID]++
+}
+
+var healthChecker = NewHealthChecker(LoadHealthConfig())
+
+// checkConnectivity 查询sys_id自身的商户基本信息，验证签名、权限及网络连通性
+func checkConnectivity(ctx context.Context, client HuifuClient, sysID string) (*MerchantBasicInfo, error) {
+	endpoint := "/v2/merchant/basicdata/query"
+	result, err := callHuifu(ctx, client, endpoint, map[string]interface{}{
+		"huifu_id": sysID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var merchant MerchantBasicInfo
+	if err := DecodeResponse(result, &merchant); err != nil {
+		return nil, err
+	}
+
+	// 连通性测试要求汇付明确返回成功码，空响应不能证明配置可用
+	normalized := NormalizeResponse(sysID, endpoint, result)
+	if normalized.RespCode == "" {
+		return nil, &HuifuError{
+			Category: CategoryInternal,
+			Endpoint: endpoint,
+			Err:      fmt.Errorf("empty response from Huifu"),
+		}
+	}
+	merchant.RespCode = firstNonEmpty(merchant.RespCode, normalized.RespCode)
+	merchant.RespDesc = firstNonEmpty(merchant.RespDesc, normalized.RespDesc)
+	return &merchant, nil
+}
+
+// getConfigHealth 查询单个sys_id的健康状态及最近检查结果
+// GET /api/configs/:sys_id/health，?check=true 时立即执行一次检查
+func getConfigHealth(c *gin.Context) {
+	sysID := c.Param("sys_id")
+
+	client, err := configManager.GetProbeClient(sysID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Configuration not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("check") == "true" {
+		healthChecker.Check(c.Request.Context(), sysID, client)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sys_id":  sysID,
+		"status":  healthChecker.Status(sysID),
+		"results": healthChecker.Results(sysID),
+		"events":  healthChecker.Events(sysID),
+	})
+}
+
+// getHealthEvents 查询所有健康状态变化事件
+func getHealthEvents(c *gin.Context) {
+	events := healthChecker.Events(c.Query("sys_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"huifu-config-system/testkit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestHealthProbesSkipJournal 定时探测不写入调用流水，不挤占业务调用的记录
+func TestHealthProbesSkipJournal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	isolateServerState(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	if got := exchange(t, setupRouter(), jsonBody(t, http.MethodPost, "/api/config", map[string]interface{}{
+		"sys_id": contractSysID, "product_id": "PAYUN", "rsa_private_key": privateKey,
+	})); got.status != http.StatusOK {
+		t.Fatalf("save config = %d %v", got.status, got.body)
+	}
+	upstream := testkit.New(t)
+	upstream.SeedMerchant(testkit.Merchant{HuifuID: contractSysID})
+	useUpstream(t, upstream, contractSysID)
+
+	checker := NewHealthChecker(LoadHealthConfig())
+	for i := 0; i < 3; i++ {
+		client, err := configManager.GetProbeClient(contractSysID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := checker.Check(context.Background(), contractSysID, client); !result.Success {
+			t.Fatalf("probe %d failed: %s", i, result.Error)
+		}
+	}
+	if records := callJournal.Query(CallFilter{SysID: contractSysID}); len(records) != 0 {
+		t.Fatalf("journal holds %d probe records, want none", len(records))
+	}
+
+	// 业务调用仍写入流水
+	client, _ := configManager.GetSDKClient(contractSysID)
+	if _, err := checkConnectivity(context.Background(), client, contractSysID); err != nil {
+		t.Fatal(err)
+	}
+	if records := callJournal.Query(CallFilter{SysID: contractSysID}); len(records) != 1 {
+		t.Fatalf("journal holds %d records after a business call, want 1", len(records))
+	}
+}
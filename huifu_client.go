@@ -1,17 +1,14 @@
 package main
 
 import (
-	"crypto"
-	"crypto/rand"
+	"context"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
-	"net/http"
+	"log"
 	"time"
+
+	"huifu-config-system/huifusign"
 )
 
 // MockHuifuClient 模拟汇付客户端实现
@@ -21,116 +18,70 @@ type MockHuifuClient struct {
 	productID     string
 	rsaPrivateKey *rsa.PrivateKey
 	isProduction  bool
-	httpClient    *http.Client
 }
 
 // NewMockHuifuClient 创建模拟客户端
 func NewMockHuifuClient(config *ConfigRequest, isProduction bool) (*MockHuifuClient, error) {
 	// 解析RSA私钥
-	block, _ := pem.Decode([]byte(config.RSAPrivateKey))
-	if block == nil {
-		return nil, fmt.Errorf("failed to parse PEM block containing the key")
-	}
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	privateKey, err := huifusign.ParsePrivateKey(config.RSAPrivateKey)
 	if err != nil {
-		// 尝试PKCS8格式
-		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse private key: %v", err)
-		}
-		var ok bool
-		privateKey, ok = key.(*rsa.PrivateKey)
-		if !ok {
-			return nil, fmt.Errorf("not an RSA private key")
-		}
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
 	}
 
+	// sys_id 本身作为已知商户，连通性测试可直接查询
+	mockState.Ensure(config.SysID)
+
 	return &MockHuifuClient{
 		sysID:         config.SysID,
 		productID:     config.ProductID,
 		rsaPrivateKey: privateKey,
 		isProduction:  isProduction,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
 	}, nil
 }
 
 // CallAPI 调用汇付API（模拟版本）
-func (c *MockHuifuClient) CallAPI(endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
-	fmt.Printf("\n=== CallAPI Start (Mock Mode) ===\n")
-	fmt.Printf("Endpoint: %s\n", endpoint)
-	fmt.Printf("Params: %+v\n", params)
+func (c *MockHuifuClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	spec, err := endpointRegistry.Lookup(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.ValidateParams(params); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withEndpointDeadline(ctx, endpoint)
+	defer cancel()
+	start := time.Now()
 
-	// 添加系统参数
-	params["sys_id"] = c.sysID
-	params["product_id"] = c.productID
-	params["timestamp"] = time.Now().Format("20060102150405")
+	// 复制参数并添加系统参数，避免修改调用方的map
+	signed := make(map[string]interface{}, len(params)+4)
+	for k, v := range params {
+		signed[k] = v
+	}
+	signed["sys_id"] = c.sysID
+	signed["product_id"] = c.productID
+	signed["timestamp"] = time.Now().Format("20060102150405")
 
-	// 生成签名（模拟）
+	// 按汇付规范对业务参数签名
+	if err := checkPhase(ctx, endpoint, PhaseSigning, start); err != nil {
+		return nil, err
+	}
 	signature, err := c.generateSignature(params)
 	if err != nil {
-		fmt.Printf("Signature generation failed: %v\n", err)
 		return nil, fmt.Errorf("failed to generate signature: %v", err)
 	}
-	params["sign"] = signature
-
-	// 打印请求参数（用于调试）
-	jsonData, _ := json.MarshalIndent(params, "", "  ")
-	fmt.Printf("Request params (formatted):\n%s\n", string(jsonData))
+	signed["sign"] = signature
 
 	// 模拟不同API的响应
-	var mockResult map[string]interface{}
-
-	switch endpoint {
-	case "/v2/merchant/busi/config", "/v2/merchant/wechat/config":
-		// 模拟微信商户配置成功响应
-		mockResult = map[string]interface{}{
-			"resp_code": "00000",
-			"resp_desc": "成功",
-			"data": map[string]interface{}{
-				"huifu_id":      params["huifu_id"],
-				"wx_woa_app_id": params["wx_woa_app_id"],
-				"wx_woa_path":   params["wx_woa_path"],
-				"fee_type":      params["fee_type"],
-				"config_status": "SUCCESS",
-				"config_time":   time.Now().Format("2006-01-02 15:04:05"),
-			},
-		}
-
-	case "/v2/merchant/busi/config/query":
-		// 模拟微信商户配置查询响应
-		mockResult = map[string]interface{}{
-			"resp_code": "00000",
-			"resp_desc": "成功",
-			"data": map[string]interface{}{
-				"huifu_id":      params["huifu_id"],
-				"wx_woa_app_id": "wx1234567890abcdef",
-				"wx_woa_path":   "pages/index/index",
-				"fee_type":      "01",
-				"config_status": "ACTIVE",
-				"config_time":   "2024-01-01 10:00:00",
-				"update_time":   time.Now().Format("2006-01-02 15:04:05"),
-			},
-		}
-
-	case "/v2/merchant/basicdata/query":
-		// 模拟商户信息查询响应
-		mockResult = map[string]interface{}{
-			"resp_code": "00000",
-			"resp_desc": "成功",
-			"data": map[string]interface{}{
-				"sys_id":      c.sysID,
-				"product_id":  c.productID,
-				"status":      "ACTIVE",
-				"create_time": "2024-01-01 10:00:00",
-			},
+	if err := checkPhase(ctx, endpoint, PhaseTransport, start); err != nil {
+		return nil, err
+	}
+	respond := func() map[string]interface{} {
+		if spec.MockResponse != nil {
+			return spec.MockResponse(c, signed)
 		}
-
-	default:
 		// 默认成功响应
-		mockResult = map[string]interface{}{
+		return map[string]interface{}{
 			"resp_code": "00000",
 			"resp_desc": "成功",
 			"data": map[string]interface{}{
@@ -139,59 +90,144 @@ func (c *MockHuifuClient) CallAPI(endpoint string, params map[string]interface{}
 		}
 	}
 
-	fmt.Printf("Mock response: %+v\n", mockResult)
-	fmt.Printf("=== CallAPI End (Mock Mode) ===\n\n")
+	// sys_id 启用了故障场景时，由命中的规则决定响应
+	var mockResult map[string]interface{}
+	if scenario, rule := scenarioManager.Match(c.sysID, endpoint, params); rule != nil {
+		log.Printf("Mock scenario %s matched: sys_id=%s endpoint=%s", scenario, c.sysID, endpoint)
+		mockResult, err = rule.apply(ctx, endpoint, start, respond)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		mockResult = respond()
+	}
 
+	if err := checkPhase(ctx, endpoint, PhaseDecoding, start); err != nil {
+		return nil, err
+	}
 	return mockResult, nil
 }
 
-// generateSignature 生成RSA签名
-func (c *MockHuifuClient) generateSignature(params map[string]interface{}) (string, error) {
-	// 将参数转换为JSON字符串
-	jsonData, err := json.Marshal(params)
+// mockBusiConfig 模拟微信商户配置，写入的配置对后续查询可见，未知商户返回商户不存在
+func mockBusiConfig(c *MockHuifuClient, params map[string]interface{}) map[string]interface{} {
+	busiReq, err := BusiConfigRequestFromParams(params)
 	if err != nil {
-		return "", err
+		return map[string]interface{}{
+			"resp_code": "10000000",
+			"resp_desc": err.Error(),
+		}
+	}
+
+	applied := mockState.ApplyConfig(busiReq.HuifuID, WeChatConfigItem{
+		FeeType:          busiReq.FeeType,
+		WxWoaAppID:       busiReq.WxWoaAppID,
+		WxWoaPath:        busiReq.WxWoaPath,
+		WxAppletAppID:    busiReq.WxAppletAppID,
+		WxSubscribeAppID: busiReq.WxSubscribeAppID,
+	})
+	if !applied {
+		return mockMerchantNotFound(busiReq.HuifuID)
+	}
+
+	return map[string]interface{}{
+		"resp_code": "00000",
+		"resp_desc": "成功",
+		"data": map[string]interface{}{
+			"huifu_id":      busiReq.HuifuID,
+			"req_seq_id":    params["req_seq_id"],
+			"req_date":      params["req_date"],
+			"wx_woa_app_id": busiReq.WxWoaAppID,
+			"wx_woa_path":   busiReq.WxWoaPath,
+			"fee_type":      busiReq.FeeType,
+			"config_status": "SUCCESS",
+			"config_time":   time.Now().Format("2006-01-02 15:04:05"),
+		},
 	}
+}
 
-	// 计算SHA256哈希
-	hashed := sha256.Sum256(jsonData)
+// mockBusiConfigQuery 模拟微信商户配置查询，返回该商户已写入的全部配置
+func mockBusiConfigQuery(c *MockHuifuClient, params map[string]interface{}) map[string]interface{} {
+	huifuID := stringField(params, "huifu_id")
+	merchant, exists := mockState.Lookup(huifuID)
+	if !exists {
+		return mockMerchantNotFound(huifuID)
+	}
 
-	// RSA签名
-	signature, err := rsa.SignPKCS1v15(rand.Reader, c.rsaPrivateKey, crypto.SHA256, hashed[:])
+	// 与汇付一致，wx_conf_list 以JSON字符串返回
+	list, err := json.Marshal(WeChatConfigList{Items: merchant.WxConfList})
 	if err != nil {
-		return "", err
+		return map[string]interface{}{
+			"resp_code": "10000000",
+			"resp_desc": err.Error(),
+		}
 	}
 
-	// Base64编码
-	return base64.StdEncoding.EncodeToString(signature), nil
+	return map[string]interface{}{
+		"resp_code": "00000",
+		"resp_desc": "成功",
+		"data": map[string]interface{}{
+			"huifu_id":     huifuID,
+			"req_seq_id":   params["req_seq_id"],
+			"req_date":     params["req_date"],
+			"wx_conf_list": string(list),
+		},
+	}
+}
+
+// mockBasicdataQuery 模拟商户信息查询响应
+func mockBasicdataQuery(c *MockHuifuClient, params map[string]interface{}) map[string]interface{} {
+	huifuID := firstNonEmpty(stringField(params, "huifu_id"), c.sysID)
+	if _, exists := mockState.Lookup(huifuID); !exists {
+		return mockMerchantNotFound(huifuID)
+	}
+
+	return map[string]interface{}{
+		"resp_code": "00000",
+		"resp_desc": "成功",
+		"data": map[string]interface{}{
+			"huifu_id":       huifuID,
+			"reg_name":       "模拟商户",
+			"short_name":     "模拟商户",
+			"ent_type":       "1",
+			"upper_huifu_id": c.sysID,
+			"status":         "ACTIVE",
+			"product_id":     c.productID,
+			"create_time":    "2024-01-01 10:00:00",
+		},
+	}
+}
+
+// generateSignature 按汇付规范对业务参数（data）签名
+func (c *MockHuifuClient) generateSignature(params map[string]interface{}) (string, error) {
+	return huifusign.SignData(c.rsaPrivateKey, params)
 }
 
 // 特定的API方法实现
 
 // ConfigureWeChatMerchant 配置微信商户
-func (c *MockHuifuClient) ConfigureWeChatMerchant(huifuID, wxAppID, wxPath string) (map[string]interface{}, error) {
+func (c *MockHuifuClient) ConfigureWeChatMerchant(ctx context.Context, huifuID, wxAppID, wxPath, feeType string) (map[string]interface{}, error) {
 	params := map[string]interface{}{
 		"huifu_id":      huifuID,
 		"wx_woa_app_id": wxAppID,
 		"wx_woa_path":   wxPath,
+		"fee_type":      feeType,
 		"req_seq_id":    generateReqSeqID(),
 		"req_date":      time.Now().Format("20060102"),
 	}
 
 	// 调用微信商户配置API
-	// 这里的endpoint需要根据实际API文档调整
-	return c.CallAPI("/v2/merchant/wechat/config", params)
+	return c.CallAPI(ctx, "/v2/merchant/busi/config", params)
 }
 
 // QueryMerchantInfo 查询商户信息
-func (c *MockHuifuClient) QueryMerchantInfo(huifuID string) (map[string]interface{}, error) {
+func (c *MockHuifuClient) QueryMerchantInfo(ctx context.Context, huifuID string) (map[string]interface{}, error) {
 	params := map[string]interface{}{
 		"huifu_id":   huifuID,
 		"req_seq_id": generateReqSeqID(),
 		"req_date":   time.Now().Format("20060102"),
 	}
 
-	return c.CallAPI("/v2/merchant/basicdata/query", params)
+	return c.CallAPI(ctx, "/v2/merchant/basicdata/query", params)
 }
 
 // generateReqSeqID 生成请求序列号
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCategory 汇付调用错误分类
+type ErrorCategory string
+
+const (
+	CategoryBusiness   ErrorCategory = "business"    // 汇付受理但业务拒绝
+	CategorySignature  ErrorCategory = "signature"   // 签名生成或验签失败
+	CategoryAuth       ErrorCategory = "auth"        // sys_id/product_id 无权限或未开通
+	CategoryNetwork    ErrorCategory = "network"     // 超时、连接失败等传输问题
+	CategoryInternal   ErrorCategory = "internal"    // 本系统内部错误
+	CategoryReplayMiss ErrorCategory = "replay_miss" // 回放模式下没有匹配的录制
+)
+
+// HuifuError 结构化的汇付调用错误
+type HuifuError struct {
+	Category ErrorCategory `json:"category"`
+	Endpoint string        `json:"endpoint,omitempty"`
+	RespCode string        `json:"resp_code,omitempty"`
+	RespDesc string        `json:"resp_desc,omitempty"`
+	SubCode  string        `json:"sub_code,omitempty"`
+	SubDesc  string        `json:"sub_desc,omitempty"`
+	ReqSeqID string        `json:"req_seq_id,omitempty"`
+	Err      error         `json:"-"`
+}
+
+func (e *HuifuError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "huifu %s error", e.Category)
+	if e.Endpoint != "" {
+		fmt.Fprintf(&b, " on %s", e.Endpoint)
+	}
+	if e.RespCode != "" {
+		fmt.Fprintf(&b, ": [%s] %s", e.RespCode, e.RespDesc)
+		if e.SubCode != "" {
+			fmt.Fprintf(&b, " ([%s] %s)", e.SubCode, e.SubDesc)
+		}
+	} else if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *HuifuError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus 将错误分类映射为HTTP状态码
+func (e *HuifuError) HTTPStatus() int {
+	switch e.Category {
+	case CategoryBusiness:
+		return http.StatusUnprocessableEntity
+	case CategorySignature:
+		return http.StatusBadGateway
+	case CategoryAuth:
+		return http.StatusForbidden
+	case CategoryNetwork:
+		var openErr *CircuitOpenError
+		if errors.As(e.Err, &openErr) {
+			return http.StatusServiceUnavailable
+		}
+		if errors.Is(e.Err, context.Canceled) {
+			return 499 // 客户端已断开
+		}
+		if errors.Is(e.Err, context.DeadlineExceeded) {
+			return http.StatusGatewayTimeout
+		}
+		return http.StatusBadGateway
+	case CategoryReplayMiss:
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// isSuccessRespCode 判断汇付响应码是否表示成功（00000 / 00000000 / 00000100 受理中）
+func isSuccessRespCode(code string) bool {
+	return strings.HasPrefix(code, "00000")
+}
+
+// CheckResponse 检查汇付响应中的resp_code，业务失败时返回 *HuifuError
+func CheckResponse(endpoint string, result map[string]interface{}) error {
+	data := responseData(result)
+	code := stringField(data, "resp_code")
+	if code == "" {
+		code = stringField(result, "resp_code")
+	}
+	if code == "" || isSuccessRespCode(code) {
+		return nil
+	}
+
+	huifuErr := &HuifuError{
+		Endpoint: endpoint,
+		RespCode: code,
+		RespDesc: firstNonEmpty(stringField(data, "resp_desc"), stringField(result, "resp_desc")),
+		SubCode:  firstNonEmpty(stringField(data, "sub_resp_code"), stringField(data, "sub_code")),
+		SubDesc:  firstNonEmpty(stringField(data, "sub_resp_desc"), stringField(data, "sub_desc")),
+		ReqSeqID: stringField(data, "req_seq_id"),
+	}
+	huifuErr.Category = classifyRespDesc(huifuErr.RespDesc)
+	return huifuErr
+}
+
+// classifyRespDesc 根据错误描述判断错误分类
+// 汇付各接口的错误码不统一，按描述关键字区分验签与权限类错误，其余视为业务拒绝
+func classifyRespDesc(desc string) ErrorCategory {
+	lower := strings.ToLower(desc)
+	switch {
+	case strings.Contains(desc, "验签") || strings.Contains(desc, "签名") || strings.Contains(lower, "signature"):
+		return CategorySignature
+	case strings.Contains(desc, "权限") || strings.Contains(desc, "未开通") || strings.Contains(lower, "not authorized"),
+		strings.Contains(desc, "不存在") && (strings.Contains(lower, "sys_id") || strings.Contains(lower, "product_id") || strings.Contains(desc, "产品")):
+		return CategoryAuth
+	}
+	return CategoryBusiness
+}
+
+// AsHuifuError 将任意调用错误转换为 *HuifuError
+func AsHuifuError(endpoint string, err error) *HuifuError {
+	if err == nil {
+		return nil
+	}
+
+	var huifuErr *HuifuError
+	if errors.As(err, &huifuErr) {
+		if huifuErr.Endpoint == "" && endpoint != "" {
+			// 错误值可能被多处共享，补充接口时复制一份
+			copied := *huifuErr
+			copied.Endpoint = endpoint
+			return &copied
+		}
+		return huifuErr
+	}
+
+	category := CategoryInternal
+	var timeoutErr *CallTimeoutError
+	var openErr *CircuitOpenError
+	var decodeErr *ResponseDecodeError
+	var missErr *NoRecordingError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &missErr):
+		category = CategoryReplayMiss
+	case errors.As(err, &timeoutErr), errors.As(err, &openErr), errors.As(err, &netErr),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		category = CategoryNetwork
+	case errors.As(err, &decodeErr):
+		// 汇付返回的响应无法解析，按上游错误处理（502）
+		category = CategoryNetwork
+	case strings.Contains(strings.ToLower(err.Error()), "signature"):
+		category = CategorySignature
+	}
+	return &HuifuError{Category: category, Endpoint: endpoint, Err: err}
+}
+
+// callHuifu 调用汇付接口并检查业务响应码，错误统一为 *HuifuError
+func callHuifu(ctx context.Context, client HuifuClient, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	result, err := client.CallAPI(ctx, endpoint, params)
+	if err != nil {
+		var conflictErr *ReqSeqConflictError
+		if errors.As(err, &conflictErr) {
+			// 流水号冲突由本系统判定，未请求汇付
+			return nil, err
+		}
+		return nil, AsHuifuError(endpoint, err)
+	}
+	if err := CheckResponse(endpoint, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// writeCallError 输出统一格式的错误响应
+func writeCallError(c *gin.Context, message string, err error) {
+	var conflictErr *ReqSeqConflictError
+	if errors.As(err, &conflictErr) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      message,
+			"details":    err.Error(),
+			"category":   "conflict",
+			"req_seq_id": conflictErr.ReqSeqID,
+		})
+		return
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    message,
+			"details":  err.Error(),
+			"category": "validation",
+			"field":    validationErr.Field,
+		})
+		return
+	}
+
+	huifuErr := AsHuifuError("", err)
+	body := gin.H{
+		"error":    message,
+		"details":  huifuErr.Error(),
+		"category": huifuErr.Category,
+	}
+	if huifuErr.RespCode != "" {
+		body["resp_code"] = huifuErr.RespCode
+		body["resp_desc"] = huifuErr.RespDesc
+	}
+	if huifuErr.SubCode != "" {
+		body["sub_code"] = huifuErr.SubCode
+		body["sub_desc"] = huifuErr.SubDesc
+	}
+	if huifuErr.ReqSeqID != "" {
+		body["req_seq_id"] = huifuErr.ReqSeqID
+	}
+	var openErr *CircuitOpenError
+	if errors.As(err, &openErr) {
+		body["next_probe"] = openErr.NextProbe
+		c.Header("Retry-After", fmt.Sprintf("%d", int(openErr.RetryAfter().Seconds())+1))
+	}
+	c.JSON(huifuErr.HTTPStatus(), body)
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,346 @@
+package huifusign
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// 私钥文件格式
+const (
+	BundlePEM          = "pem"           // 未加密的PEM
+	BundleEncryptedPEM = "encrypted_pem" // 加密的PEM（PKCS#8 ENCRYPTED PRIVATE KEY 或传统 Proc-Type 加密）
+	BundlePKCS12       = "pkcs12"        // .pfx/.p12
+)
+
+// ErrIncorrectPassphrase 口令错误
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+// maxKDFIterations 一个文件中全部口令派生的迭代次数总上限（按派生的哈希输出块计），
+// 防止上传的文件以极大的迭代次数或大量加密内容耗尽CPU；100万次PBKDF2-SHA256约需0.3秒，OpenSSL默认每次2048次
+const maxKDFIterations = 1000000
+
+// errIterationCount 文件中的迭代次数超出范围
+var errIterationCount = errors.New("unsupported key derivation iteration count")
+
+// kdfBudget 一个文件剩余可用的派生迭代次数
+type kdfBudget struct {
+	remaining int
+}
+
+func newKDFBudget() *kdfBudget {
+	return &kdfBudget{remaining: maxKDFIterations}
+}
+
+// spend 扣减一次派生的开销（迭代次数×输出块数），须在派生密钥前调用
+func (b *kdfBudget) spend(iterations, blocks int) error {
+	if iterations < 1 || iterations > maxKDFIterations {
+		return fmt.Errorf("%w %d, must be between 1 and %d", errIterationCount, iterations, maxKDFIterations)
+	}
+	if cost := iterations * blocks; cost <= b.remaining {
+		b.remaining -= cost
+		return nil
+	}
+	return fmt.Errorf("%w: the file needs more than %d iterations in total", errIterationCount, maxKDFIterations)
+}
+
+// KeyBundle 从私钥文件解出的私钥及证书，全部在内存中处理
+type KeyBundle struct {
+	Format       string
+	PrivateKey   *rsa.PrivateKey
+	Certificates []*x509.Certificate // 与私钥匹配的证书排在最前
+}
+
+// Certificate 与私钥匹配的证书，没有时返回nil
+func (b *KeyBundle) Certificate() *x509.Certificate {
+	if len(b.Certificates) == 0 || !publicKeyMatches(b.Certificates[0], b.PrivateKey) {
+		return nil
+	}
+	return b.Certificates[0]
+}
+
+// ParseKeyBundle 解析私钥文件：PEM（可加密，可附带证书）或PKCS#12（.pfx/.p12）
+// 私钥必须为RSA；附带证书时至少一张证书的公钥必须与私钥匹配
+// 文件中全部口令派生共用 maxKDFIterations 的迭代次数
+func ParseKeyBundle(data []byte, passphrase string) (*KeyBundle, error) {
+	var bundle *KeyBundle
+	var err error
+	budget := newKDFBudget()
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		bundle, err = parsePEMBundle(data, passphrase, budget)
+	} else {
+		bundle, err = parsePKCS12Bundle(data, passphrase, budget)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bundle.Certificates) > 0 {
+		for i, cert := range bundle.Certificates {
+			if publicKeyMatches(cert, bundle.PrivateKey) {
+				bundle.Certificates[0], bundle.Certificates[i] = cert, bundle.Certificates[0]
+				return bundle, nil
+			}
+		}
+		return nil, fmt.Errorf("none of the certificates in the file matches the private key")
+	}
+	return bundle, nil
+}
+
+func parsePEMBundle(data []byte, passphrase string, budget *kdfBudget) (*KeyBundle, error) {
+	bundle := &KeyBundle{Format: BundlePEM}
+	rest := bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %v", err)
+			}
+			bundle.Certificates = append(bundle.Certificates, cert)
+			continue
+		}
+
+		if bundle.PrivateKey != nil {
+			return nil, fmt.Errorf("file contains more than one private key")
+		}
+		key, encrypted, err := decryptPEMKey(block, passphrase, budget)
+		if err != nil {
+			return nil, err
+		}
+		if encrypted {
+			bundle.Format = BundleEncryptedPEM
+		}
+		bundle.PrivateKey = key
+	}
+
+	if bundle.PrivateKey == nil {
+		return nil, fmt.Errorf("file contains no private key")
+	}
+	return bundle, nil
+}
+
+// decryptPEMKey 解析单个私钥块，加密时用口令解密
+func decryptPEMKey(block *pem.Block, passphrase string, budget *kdfBudget) (*rsa.PrivateKey, bool, error) {
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if passphrase == "" {
+			return nil, true, fmt.Errorf("private key is encrypted, a passphrase is required")
+		}
+		der, err := decryptPKCS8(block.Bytes, passphrase, budget)
+		if err != nil {
+			return nil, true, err
+		}
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, true, ErrIncorrectPassphrase
+		}
+		rsaKey, err := rsaPrivateKey(key)
+		return rsaKey, true, err
+	case strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED"):
+		if passphrase == "" {
+			return nil, true, fmt.Errorf("private key is encrypted, a passphrase is required")
+		}
+		// 传统OpenSSL加密格式（DEK-Info），仅为兼容旧文件；以一次MD5派生密钥
+		if err := budget.spend(1, 1); err != nil {
+			return nil, true, err
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err != nil {
+			if errors.Is(err, x509.IncorrectPasswordError) {
+				return nil, true, ErrIncorrectPassphrase
+			}
+			return nil, true, fmt.Errorf("failed to decrypt private key: %v", err)
+		}
+		key, err := parsePrivateKeyPEM(string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})))
+		if err != nil && (block.Type == "RSA PRIVATE KEY" || block.Type == "PRIVATE KEY") {
+			// 解密结果不是合法私钥，多为口令错误
+			return nil, true, ErrIncorrectPassphrase
+		}
+		return key, true, err
+	default:
+		key, err := parsePrivateKeyPEM(string(pem.EncodeToMemory(block)))
+		return key, false, err
+	}
+}
+
+func parsePKCS12Bundle(data []byte, passphrase string, budget *kdfBudget) (*KeyBundle, error) {
+	// 不使用 x/crypto/pkcs12：其解密加密内容中的私钥包时无法限制迭代次数
+	blocks, err := decodePKCS12(data, passphrase, budget)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrIncorrectPassphrase), errors.Is(err, errIterationCount):
+			return nil, err
+		case errors.Is(err, errUnsupportedPKCS12):
+			return nil, fmt.Errorf("unsupported PKCS#12 file (%v), re-export it with `openssl pkcs12 -export` or upload an encrypted PEM instead", err)
+		default:
+			return nil, fmt.Errorf("file is neither PEM nor PKCS#12: %v", err)
+		}
+	}
+
+	bundle := &KeyBundle{Format: BundlePKCS12}
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %v", err)
+			}
+			bundle.Certificates = append(bundle.Certificates, cert)
+		default:
+			// 私钥均已转为PKCS#1（RSA）或SEC1（EC）
+			if bundle.PrivateKey != nil {
+				return nil, fmt.Errorf("file contains more than one private key")
+			}
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				if _, ecErr := x509.ParseECPrivateKey(block.Bytes); ecErr == nil {
+					return nil, fmt.Errorf("got an ECDSA private key, Huifu requires an RSA private key")
+				}
+				return nil, fmt.Errorf("invalid private key in PKCS#12 file: %v", err)
+			}
+			bundle.PrivateKey = key
+		}
+	}
+
+	if bundle.PrivateKey == nil {
+		return nil, fmt.Errorf("file contains no private key")
+	}
+	return bundle, nil
+}
+
+func publicKeyMatches(cert *x509.Certificate, key *rsa.PrivateKey) bool {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	return ok && key.PublicKey.Equal(pub)
+}
+
+// PKCS#8加密（PBES2，RFC 8018）所用的OID
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 解密 ENCRYPTED PRIVATE KEY，返回PKCS#8 DER
+func decryptPKCS8(der []byte, passphrase string, budget *kdfBudget) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted private key: %v", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption %v, re-encrypt it with `openssl pkcs8 -topk8 -v2 aes-256-cbc`", info.Algorithm.Algorithm)
+	}
+	return decryptPBES2(info.Algorithm, info.EncryptedData, passphrase, budget)
+}
+
+// decryptPBES2 按PBES2（PBKDF2，AES-CBC或3DES-CBC）解密
+func decryptPBES2(algorithm pkix.AlgorithmIdentifier, encrypted []byte, passphrase string, budget *kdfBudget) ([]byte, error) {
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %v", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %v, only PBKDF2 is supported", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 parameters: %v", err)
+	}
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA256):
+		prf = sha256.New
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 hash %v", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen, newCipher = 16, aes.NewCipher
+	case scheme.Equal(oidAES192CBC):
+		keyLen, newCipher = 24, aes.NewCipher
+	case scheme.Equal(oidAES256CBC):
+		keyLen, newCipher = 32, aes.NewCipher
+	case scheme.Equal(oidDESEDE3CBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported cipher %v, use AES-CBC or 3DES-CBC", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("invalid cipher parameters: %v", err)
+	}
+
+	if err := budget.spend(kdf.IterationCount, (keyLen+prf().Size()-1)/prf().Size()); err != nil {
+		return nil, err
+	}
+	block, err := newCipher(pbkdf2.Key([]byte(passphrase), kdf.Salt, kdf.IterationCount, keyLen, prf))
+	if err != nil {
+		return nil, err
+	}
+	return decryptCBC(block, iv, encrypted)
+}
+
+// decryptCBC CBC解密并去除PKCS#7填充，填充不合法说明口令错误
+func decryptCBC(block cipher.Block, iv, encrypted []byte) ([]byte, error) {
+	if len(iv) != block.BlockSize() || len(encrypted) == 0 || len(encrypted)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted data: bad IV or data length")
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, encrypted)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > block.BlockSize() || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plain[:len(plain)-pad], nil
+}
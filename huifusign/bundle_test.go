@@ -0,0 +1,198 @@
+package huifusign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata 中的文件由同一私钥及自签名证书生成，口令均为 secret：
+//
+//	openssl pkcs12 -export -inkey merchant.key -in merchant.crt -out merchant.p12                  # OpenSSL 3 默认：PBES2 + SHA-256 MAC
+//	openssl pkcs12 -export -legacy -inkey merchant.key -in merchant.crt -out merchant-legacy.p12   # 3DES/RC2 + SHA-1 MAC
+//	openssl pkcs8 -topk8 -v2 aes-256-cbc -v2prf hmacWithSHA256 -in merchant.key -out merchant-pkcs8.pem
+//	openssl rsa -aes256 -traditional -in merchant.key -out merchant-legacy.pem
+const testPassphrase = "secret"
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseKeyBundle(t *testing.T) {
+	tests := []struct {
+		file            string
+		format          string
+		withCertificate bool
+	}{
+		{"merchant.p12", BundlePKCS12, true},
+		{"merchant-legacy.p12", BundlePKCS12, true},
+		{"merchant-pkcs8.pem", BundleEncryptedPEM, false},
+		{"merchant-legacy.pem", BundleEncryptedPEM, false},
+	}
+
+	var first *KeyBundle
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data := readTestdata(t, tt.file)
+
+			bundle, err := ParseKeyBundle(data, testPassphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bundle.Format != tt.format {
+				t.Errorf("Format = %s, want %s", bundle.Format, tt.format)
+			}
+			if cert := bundle.Certificate(); (cert != nil) != tt.withCertificate {
+				t.Errorf("Certificate() = %v, want certificate: %v", cert, tt.withCertificate)
+			} else if cert != nil && cert.Subject.CommonName != "6666000123456789" {
+				t.Errorf("certificate CN = %s", cert.Subject.CommonName)
+			}
+			if first == nil {
+				first = bundle
+			} else if !bundle.PrivateKey.Equal(first.PrivateKey) {
+				t.Error("private key differs from the other fixtures")
+			}
+
+			if _, err := ParseKeyBundle(data, "wrong"); !errors.Is(err, ErrIncorrectPassphrase) {
+				t.Errorf("wrong passphrase: err = %v, want ErrIncorrectPassphrase", err)
+			}
+		})
+	}
+}
+
+func TestParseKeyBundleRequiresPassphrase(t *testing.T) {
+	for _, file := range []string{"merchant-pkcs8.pem", "merchant-legacy.pem"} {
+		if _, err := ParseKeyBundle(readTestdata(t, file), ""); err == nil {
+			t.Errorf("%s: parsed without a passphrase", file)
+		}
+	}
+}
+
+func TestIterationCountLimit(t *testing.T) {
+	const iterations = 1 << 30
+
+	t.Run("PBES2 private key", func(t *testing.T) {
+		block, _ := pem.Decode(readTestdata(t, "merchant-pkcs8.pem"))
+		var info encryptedPrivateKeyInfo
+		if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+			t.Fatal(err)
+		}
+		var params pbes2Params
+		if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+			t.Fatal(err)
+		}
+		var kdf pbkdf2Params
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			t.Fatal(err)
+		}
+
+		kdf.IterationCount = iterations
+		params.KeyDerivationFunc.Parameters = mustMarshal(t, kdf)
+		info.Algorithm.Parameters = mustMarshal(t, params)
+		data := pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: mustMarshal(t, info).FullBytes})
+
+		if _, err := ParseKeyBundle(data, testPassphrase); !errors.Is(err, errIterationCount) {
+			t.Fatalf("err = %v, want the iteration limit", err)
+		}
+	})
+
+	for _, file := range []string{"merchant.p12", "merchant-legacy.p12"} {
+		t.Run(file+" MAC", func(t *testing.T) {
+			var pfx pfxPDU
+			if _, err := asn1.Unmarshal(readTestdata(t, file), &pfx); err != nil {
+				t.Fatal(err)
+			}
+			pfx.MacData.Iterations = iterations
+
+			if _, err := ParseKeyBundle(mustMarshal(t, pfx).FullBytes, testPassphrase); !errors.Is(err, errIterationCount) {
+				t.Fatalf("err = %v, want the iteration limit", err)
+			}
+		})
+	}
+
+	if err := verifyPKCS12MAC(&macData{Mac: digestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}}, Iterations: iterations}, nil, testPassphrase, newKDFBudget()); !errors.Is(err, errIterationCount) {
+		t.Fatalf("verifyPKCS12MAC err = %v, want the iteration limit", err)
+	}
+}
+
+// TestIterationBudget 每次派生都在上限内、但合计超出上限的文件被拒绝
+func TestIterationBudget(t *testing.T) {
+	budget := newKDFBudget()
+	if err := budget.spend(maxKDFIterations/2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := budget.spend(maxKDFIterations/2, 2); !errors.Is(err, errIterationCount) {
+		t.Fatalf("second spend err = %v, want the iteration limit", err)
+	}
+
+	// MAC用去几乎全部迭代次数并重新计算MAC，使文件仍然有效，之后解密内容时超出合计上限
+	var pfx pfxPDU
+	if _, err := asn1.Unmarshal(readTestdata(t, "merchant.p12"), &pfx); err != nil {
+		t.Fatal(err)
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		t.Fatal(err)
+	}
+	pfx.MacData.Iterations = maxKDFIterations - 1000
+	password, _ := bmpString(testPassphrase)
+	key := pkcs12KDF(sha256.New, 64, pfx.MacData.MacSalt, password, pfx.MacData.Iterations, 3, sha256.Size)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(authSafe)
+	pfx.MacData.Mac.Digest = mac.Sum(nil)
+
+	if _, err := ParseKeyBundle(mustMarshal(t, pfx).FullBytes, testPassphrase); !errors.Is(err, errIterationCount) {
+		t.Fatalf("err = %v, want the total iteration limit", err)
+	}
+}
+
+// TestRC2 RFC 2268 第5节的测试向量
+func TestRC2(t *testing.T) {
+	tests := []struct {
+		key, plain, cipher string
+		bits               int
+	}{
+		{"0000000000000000", "0000000000000000", "ebb773f993278eff", 63},
+		{"ffffffffffffffff", "ffffffffffffffff", "278b27e42e2f0d49", 64},
+		{"3000000000000000", "1000000000000001", "30649edf9be7d2c2", 64},
+		{"88bca90e90875a7f0f79c384627bafb2", "0000000000000000", "2269552ab0f85ca6", 128},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		plain, _ := hex.DecodeString(tt.plain)
+		block := newRC2Cipher(key, tt.bits)
+
+		out := make([]byte, 8)
+		block.Encrypt(out, plain)
+		if got := hex.EncodeToString(out); got != tt.cipher {
+			t.Errorf("Encrypt(%s) = %s, want %s", tt.key, got, tt.cipher)
+		}
+		block.Decrypt(out, out)
+		if got := hex.EncodeToString(out); got != tt.plain {
+			t.Errorf("Decrypt(%s) = %s, want %s", tt.key, got, tt.plain)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, value interface{}) asn1.RawValue {
+	t.Helper()
+
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{FullBytes: der}
+}
@@ -0,0 +1,160 @@
+package huifusign
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// HuifuPublicKey 汇付平台公钥（生产与联调环境相同），用于验证汇付响应及通知的签名
+const HuifuPublicKey = `MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAkMX8p3GyMw3gk6x72h20NOk3L9+Nn9mOVP6+YoBwCe7Zs4QmYrA/etFRZw2TQrSc51wgtCkJi1/x8Wl7maPL1uH2+77JFlPv7H/F4Lr2I2LXgnllg6PtwOSw/qvGYInVVB4kL85VQl0/8ObyxBUdJ43I0z/u8hJb2gwujSudOGizbeqQXAYrwcNy+e+cjodpPy9unpJjBfa4Wz2eVLLvUYYKZKdRn6pZR2cQsMBvL30K4cFlZqlJ9iP2hTG3gaiZJ9JrjTigwki0g9pbTDXiPACfuF1nOeObvLD22zBbgn1kwgfsqoG67z7g84u2jvfUFCzX1JRgd0xfNorTRkS2RQIDAQAB`
+
+// ParsePublicKey 解析RSA公钥，支持PEM及不带标记的base64 DER（PKIX或PKCS#1）
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	der, err := decodeKey(s)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not RSA")
+		}
+		return rsaKey, nil
+	}
+	key, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey 解析RSA私钥，支持PKCS#1（BEGIN RSA PRIVATE KEY）、PKCS#8（BEGIN PRIVATE KEY）PEM
+// 及不带标记的base64 DER；可含换行、Windows换行或转义的 \n。
+// 加密私钥、非RSA私钥、公钥或证书以及PEM标记与内容不符的私钥返回明确的错误
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "\ufeff"))
+	s = strings.ReplaceAll(s, `\n`, "\n")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil, fmt.Errorf("private key is empty")
+	}
+	if strings.Contains(s, "-----BEGIN") {
+		return parsePrivateKeyPEM(s)
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, fmt.Errorf("private key is neither PEM nor base64: %v", err)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return rsaPrivateKey(key)
+	}
+	if _, err := ParsePublicKey(s); err == nil {
+		return nil, fmt.Errorf("got a public key, expected the merchant private key")
+	}
+	return nil, fmt.Errorf("base64 content is neither a PKCS#1 nor a PKCS#8 private key")
+}
+
+func parsePrivateKeyPEM(s string) (*rsa.PrivateKey, error) {
+	block, rest := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("malformed PEM: missing or broken BEGIN/END lines")
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("PEM contains more than one block, expected only the private key")
+	}
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		return nil, fmt.Errorf("private key is encrypted, decrypt it or import it with its passphrase")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err == nil {
+			return key, nil
+		}
+		if _, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes); pkcs8Err == nil {
+			return nil, fmt.Errorf("PEM header says RSA PRIVATE KEY (PKCS#1) but the content is PKCS#8, use BEGIN PRIVATE KEY or submit the bare base64")
+		}
+		return nil, fmt.Errorf("invalid PKCS#1 private key: %v", err)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			return rsaPrivateKey(key)
+		}
+		if _, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes); pkcs1Err == nil {
+			return nil, fmt.Errorf("PEM header says PRIVATE KEY (PKCS#8) but the content is PKCS#1, use BEGIN RSA PRIVATE KEY or submit the bare base64")
+		}
+		return nil, fmt.Errorf("invalid PKCS#8 private key: %v", err)
+	case "ENCRYPTED PRIVATE KEY":
+		return nil, fmt.Errorf("private key is encrypted, decrypt it or import it with its passphrase")
+	case "EC PRIVATE KEY", "DSA PRIVATE KEY", "OPENSSH PRIVATE KEY":
+		return nil, fmt.Errorf("got a %s, Huifu requires an RSA private key", block.Type)
+	case "PUBLIC KEY", "RSA PUBLIC KEY", "CERTIFICATE":
+		return nil, fmt.Errorf("got a %s, expected the merchant private key", block.Type)
+	default:
+		return nil, fmt.Errorf("unsupported PEM type %q, expected RSA PRIVATE KEY or PRIVATE KEY", block.Type)
+	}
+}
+
+func rsaPrivateKey(key interface{}) (*rsa.PrivateKey, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return nil, fmt.Errorf("got an ECDSA private key, Huifu requires an RSA private key")
+	case ed25519.PrivateKey:
+		return nil, fmt.Errorf("got an Ed25519 private key, Huifu requires an RSA private key")
+	default:
+		return nil, fmt.Errorf("got a %T private key, Huifu requires an RSA private key", key)
+	}
+}
+
+// EncodePrivateKey 将私钥编码为不带标记、不换行的base64 PKCS#8 DER，即SDK配置中 rsa_merch_private_key 的格式
+func EncodePrivateKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// EncodePrivateKeyPEM 将私钥编码为PKCS#8 PEM（BEGIN PRIVATE KEY）
+func EncodePrivateKeyPEM(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// EncodePublicKey 将公钥编码为不带标记的base64 PKIX DER，即SDK配置中 rsa_huifu_public_key 的格式
+func EncodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return block.Bytes, nil
+	}
+	s = strings.Join(strings.Fields(s), "")
+	der, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is neither PEM nor base64: %v", err)
+	}
+	return der, nil
+}
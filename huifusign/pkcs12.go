@@ -0,0 +1,323 @@
+package huifusign
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"unicode/utf16"
+)
+
+// errUnsupportedPKCS12 PKCS#12文件使用了未支持的结构或算法
+var errUnsupportedPKCS12 = errors.New("unsupported PKCS#12 structure")
+
+// PKCS#12（RFC 7292）所用的OID
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509Certificate  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidPBEWithSHAAnd3KeyTDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd40BitRC2CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidSHA1                     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA512                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// pbeParams PKCS#12传统PBE算法的参数
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// decodePKCS12 解析PKCS#12：OpenSSL 3 默认的PBES2加密及SHA-256/SHA-512 MAC，
+// 或传统的3DES/40位RC2加密及SHA-1 MAC（openssl pkcs12 -legacy）；每次口令派生前扣减budget
+// 返回的私钥块为PKCS#1
+func decodePKCS12(data []byte, passphrase string, budget *kdfBudget) ([]*pem.Block, error) {
+	var pfx pfxPDU
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+	if pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, errUnsupportedPKCS12
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+	if err := verifyPKCS12MAC(&pfx.MacData, authSafe, passphrase, budget); err != nil {
+		return nil, err
+	}
+
+	var contents []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+	}
+
+	var blocks []*pem.Block
+	for _, ci := range contents {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var encrypted encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &encrypted); err != nil {
+				return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+			}
+			info := encrypted.EncryptedContentInfo
+			plain, err := decryptPKCS12Content(info.ContentEncryptionAlgorithm, info.EncryptedContent, passphrase, budget)
+			if err != nil {
+				return nil, err
+			}
+			safeContents = plain
+		default:
+			return nil, errUnsupportedPKCS12
+		}
+
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			return nil, fmt.Errorf("invalid PKCS#12 data: %v", err)
+		}
+		for _, bag := range bags {
+			block, err := convertSafeBag(&bag, passphrase, budget)
+			if err != nil {
+				return nil, err
+			}
+			if block != nil {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// convertSafeBag 将证书及私钥转为PEM块，忽略其他类型
+func convertSafeBag(bag *safeBag, passphrase string, budget *kdfBudget) (*pem.Block, error) {
+	var pkcs8 []byte
+	switch {
+	case bag.ID.Equal(oidCertBag):
+		var cert certBag
+		if _, err := asn1.Unmarshal(bag.Value.Bytes, &cert); err != nil {
+			return nil, fmt.Errorf("invalid certificate bag: %v", err)
+		}
+		if !cert.ID.Equal(oidCertTypeX509Certificate) {
+			return nil, nil
+		}
+		return &pem.Block{Type: "CERTIFICATE", Bytes: cert.Data}, nil
+	case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+		var info encryptedPrivateKeyInfo
+		if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+			return nil, fmt.Errorf("invalid encrypted private key: %v", err)
+		}
+		der, err := decryptPKCS12Content(info.Algorithm, info.EncryptedData, passphrase, budget)
+		if err != nil {
+			return nil, err
+		}
+		pkcs8 = der
+	case bag.ID.Equal(oidKeyBag):
+		pkcs8 = bag.Value.Bytes
+	default:
+		return nil, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(pkcs8)
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	rsaKey, err := rsaPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, nil
+}
+
+// verifyPKCS12MAC 校验MAC，MAC密钥按RFC 7292附录B由口令派生
+func verifyPKCS12MAC(mac *macData, message []byte, passphrase string, budget *kdfBudget) error {
+	var newHash func() hash.Hash
+	var blockSize int
+	switch algorithm := mac.Mac.Algorithm.Algorithm; {
+	case len(algorithm) == 0:
+		return fmt.Errorf("PKCS#12 file has no MAC")
+	case algorithm.Equal(oidSHA1):
+		newHash, blockSize = sha1.New, 64
+	case algorithm.Equal(oidSHA256):
+		newHash, blockSize = sha256.New, 64
+	case algorithm.Equal(oidSHA512):
+		newHash, blockSize = sha512.New, 128
+	default:
+		return errUnsupportedPKCS12
+	}
+
+	if err := budget.spend(mac.Iterations, 1); err != nil {
+		return err
+	}
+	password, err := bmpString(passphrase)
+	if err != nil {
+		return err
+	}
+	key := pkcs12KDF(newHash, blockSize, mac.MacSalt, password, mac.Iterations, 3, newHash().Size())
+	h := hmac.New(newHash, key)
+	h.Write(message)
+	if !hmac.Equal(h.Sum(nil), mac.Mac.Digest) {
+		return ErrIncorrectPassphrase
+	}
+	return nil
+}
+
+// decryptPKCS12Content 解密加密内容或私钥包：PBES2，或PKCS#12传统PBE
+func decryptPKCS12Content(algorithm pkix.AlgorithmIdentifier, encrypted []byte, passphrase string, budget *kdfBudget) ([]byte, error) {
+	if algorithm.Algorithm.Equal(oidPBES2) {
+		return decryptPBES2(algorithm, encrypted, passphrase, budget)
+	}
+
+	// 传统PBE：按RFC 7292附录B以SHA-1由BMPString口令派生密钥（ID 1）及IV（ID 2）
+	var keyLen int
+	var newCipher func([]byte) (cipher.Block, error)
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTDESCBC):
+		keyLen, newCipher = 24, des.NewTripleDESCipher
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+		keyLen, newCipher = 5, func(key []byte) (cipher.Block, error) { return newRC2Cipher(key, 40), nil }
+	default:
+		return nil, fmt.Errorf("%w: encryption %v", errUnsupportedPKCS12, algorithm.Algorithm)
+	}
+	var params pbeParams
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBE parameters: %v", err)
+	}
+	if err := budget.spend(params.Iterations, (keyLen+sha1.Size-1)/sha1.Size+1); err != nil {
+		return nil, err
+	}
+	password, err := bmpString(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key := pkcs12KDF(sha1.New, 64, params.Salt, password, params.Iterations, 1, keyLen)
+	iv := pkcs12KDF(sha1.New, 64, params.Salt, password, params.Iterations, 2, des.BlockSize)
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return decryptCBC(block, iv, encrypted)
+}
+
+// pkcs12KDF RFC 7292 附录B.2 的密钥派生函数
+func pkcs12KDF(newHash func() hash.Hash, v int, salt, password []byte, iterations int, id byte, size int) []byte {
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(data)+v-1)/v))
+		for i := range out {
+			out[i] = data[i%len(data)]
+		}
+		return out
+	}
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	in := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		h := newHash()
+		h.Write(d)
+		h.Write(in)
+		a := h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(v*8)
+		b := fill(a)[:v]
+		for j := 0; j < len(in); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(in[j+k]) + int(b[k]) + carry
+				in[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// bmpString 口令编码为以两个零字节结尾的UTF-16BE
+func bmpString(s string) ([]byte, error) {
+	out := make([]byte, 0, 2*len(s)+2)
+	for _, r := range s {
+		if r > 0xffff || utf16.IsSurrogate(r) {
+			return nil, fmt.Errorf("passphrase contains characters outside the Basic Multilingual Plane")
+		}
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return append(out, 0, 0), nil
+}
@@ -0,0 +1,132 @@
+// Package huifusign 汇付v2接口的签名与验签
+//
+// 签名原文的规范化规则（请求、同步响应相同）：
+//
+//   - 对 data 对象的各层key按字节序排序，值为null的字段不参与签名
+//   - 序列化为紧凑JSON，不转义 <、>、& 等HTML字符，中文按UTF-8原样输出
+//   - 数字按报文中的原始字面量输出，不做浮点转换
+//
+// data 以JSON字符串形式传输时（如异步通知的 resp_data），直接对字符串内容验签，不再规范化。
+// 签名算法为RSA-SHA256（PKCS#1 v1.5），签名值base64编码。
+//
+// 包内附带的测试向量见 Vectors，可用 CheckVectors 校验实现。
+package huifusign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrSignatureMismatch 签名与原文不匹配
+var ErrSignatureMismatch = errors.New("signature mismatch")
+
+// Canonicalize 生成data对象的签名原文
+func Canonicalize(data map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(dropNulls(data)); err != nil {
+		return nil, fmt.Errorf("failed to canonicalize data: %v", err)
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// dropNulls 复制数据并去除对象中值为null的字段，数组元素保持不变
+func dropNulls(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			if item != nil {
+				copied[k] = dropNulls(item)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = dropNulls(item)
+		}
+		return copied
+	}
+	return v
+}
+
+// ParseData 解析报文中的data字段，返回签名原文及解析后的对象
+// data 为JSON字符串时签名原文即字符串内容，为JSON对象时为规范化结果
+func ParseData(raw json.RawMessage) ([]byte, map[string]interface{}, error) {
+	content := []byte(raw)
+	var encoded string
+	isString := json.Unmarshal(raw, &encoded) == nil
+	if isString {
+		content = []byte(encoded)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	data := make(map[string]interface{})
+	if err := decoder.Decode(&data); err != nil {
+		return nil, nil, fmt.Errorf("data is not a JSON object: %v", err)
+	}
+	if isString {
+		return content, data, nil
+	}
+	canonical, err := Canonicalize(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return canonical, data, nil
+}
+
+// SignData 对请求或响应的data对象签名
+func SignData(key *rsa.PrivateKey, data map[string]interface{}) (string, error) {
+	content, err := Canonicalize(data)
+	if err != nil {
+		return "", err
+	}
+	return Sign(key, content)
+}
+
+// VerifyData 校验报文中data字段（对象或JSON字符串）的签名
+func VerifyData(key *rsa.PublicKey, raw json.RawMessage, sign string) error {
+	content, _, err := ParseData(raw)
+	if err != nil {
+		return err
+	}
+	return Verify(key, content, sign)
+}
+
+// VerifyNotification 校验异步通知：对 resp_data 原文验签
+func VerifyNotification(key *rsa.PublicKey, respData, sign string) error {
+	return Verify(key, []byte(respData), sign)
+}
+
+// Sign 使用RSA-SHA256签名，返回base64编码的签名
+func Sign(key *rsa.PrivateKey, content []byte) (string, error) {
+	hashed := sha256.Sum256(content)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// Verify 校验base64编码的RSA-SHA256签名
+func Verify(key *rsa.PublicKey, content []byte, sign string) error {
+	signature, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("sign is not valid base64: %v", err)
+	}
+	hashed := sha256.Sum256(content)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
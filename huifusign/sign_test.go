@@ -0,0 +1,102 @@
+package huifusign
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckVectors(t *testing.T) {
+	if err := CheckVectors(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVectorsSignWithBundledKey(t *testing.T) {
+	set, err := Vectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Vectors) == 0 {
+		t.Fatal("no bundled vectors")
+	}
+
+	privateKey, err := ParsePrivateKey(set.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ParsePublicKey(set.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vector := range set.Vectors {
+		if err := Verify(publicKey, []byte(vector.Canonical), vector.Sign); err != nil {
+			t.Errorf("vector %s: %v", vector.Name, err)
+		}
+		if err := Verify(publicKey, []byte(vector.Canonical+" "), vector.Sign); err != ErrSignatureMismatch {
+			t.Errorf("vector %s: tampered content verified, got %v", vector.Name, err)
+		}
+	}
+
+	sign, err := SignData(privateKey, map[string]interface{}{"b": "2", "a": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyData(&privateKey.PublicKey, json.RawMessage(`{"a":"1","b":"2"}`), sign); err != nil {
+		t.Fatalf("VerifyData: %v", err)
+	}
+}
+
+func TestParseData(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		content string
+		field   string
+		want    interface{}
+	}{
+		{
+			name:    "object is canonicalized",
+			raw:     `{"z": "1", "a": {"y": null, "x": 10.50}, "html": "<a&b>"}`,
+			content: `{"a":{"x":10.50},"html":"<a&b>","z":"1"}`,
+			field:   "z",
+			want:    "1",
+		},
+		{
+			name:    "string content is used verbatim",
+			raw:     `"{\"z\": \"1\",  \"a\": null}"`,
+			content: `{"z": "1",  "a": null}`,
+			field:   "z",
+			want:    "1",
+		},
+		{
+			name:    "numbers keep their literal form",
+			raw:     `{"amount": 1.00}`,
+			content: `{"amount":1.00}`,
+			field:   "amount",
+			want:    json.Number("1.00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, data, err := ParseData(json.RawMessage(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.content {
+				t.Errorf("content = %s, want %s", content, tt.content)
+			}
+			if data[tt.field] != tt.want {
+				t.Errorf("data[%s] = %#v, want %#v", tt.field, data[tt.field], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDataRejectsNonObjects(t *testing.T) {
+	for _, raw := range []string{`"<html>502</html>"`, `[1,2]`, `"[1,2]"`, `not json`} {
+		if _, _, err := ParseData(json.RawMessage(raw)); err == nil {
+			t.Errorf("ParseData(%s) succeeded, want error", raw)
+		}
+	}
+}
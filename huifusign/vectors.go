@@ -0,0 +1,97 @@
+package huifusign
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// VectorKind 测试向量类型
+type VectorKind string
+
+const (
+	// VectorData 请求或同步响应的data（对象或JSON字符串）
+	VectorData VectorKind = "data"
+	// VectorNotification 异步通知的 resp_data 原文
+	VectorNotification VectorKind = "notification"
+)
+
+// Vector 一条签名测试向量
+// RSA-SHA256（PKCS#1 v1.5）签名是确定性的，相同原文和私钥得到相同签名
+type Vector struct {
+	Name      string          `json:"name"`
+	Kind      VectorKind      `json:"kind"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	RespData  string          `json:"resp_data,omitempty"`
+	Canonical string          `json:"canonical"`
+	Sign      string          `json:"sign"`
+}
+
+// VectorSet 测试向量集合及所用的测试密钥（仅用于测试，不得用于任何真实商户）
+type VectorSet struct {
+	Description string   `json:"description"`
+	PrivateKey  string   `json:"private_key"`
+	PublicKey   string   `json:"public_key"`
+	Vectors     []Vector `json:"vectors"`
+}
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vectors 返回包内附带的测试向量
+func Vectors() (*VectorSet, error) {
+	var set VectorSet
+	if err := json.Unmarshal(vectorsJSON, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse test vectors: %v", err)
+	}
+	return &set, nil
+}
+
+// CheckVectors 用附带的测试向量校验规范化、签名与验签，返回首个不一致的向量
+func CheckVectors() error {
+	set, err := Vectors()
+	if err != nil {
+		return err
+	}
+	privateKey, err := ParsePrivateKey(set.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("test vector private key: %v", err)
+	}
+	publicKey, err := ParsePublicKey(set.PublicKey)
+	if err != nil {
+		return fmt.Errorf("test vector public key: %v", err)
+	}
+
+	for _, vector := range set.Vectors {
+		content, err := vector.content()
+		if err != nil {
+			return fmt.Errorf("vector %s: %v", vector.Name, err)
+		}
+		if string(content) != vector.Canonical {
+			return fmt.Errorf("vector %s: canonical form %s, want %s", vector.Name, content, vector.Canonical)
+		}
+		sign, err := Sign(privateKey, content)
+		if err != nil {
+			return fmt.Errorf("vector %s: %v", vector.Name, err)
+		}
+		if sign != vector.Sign {
+			return fmt.Errorf("vector %s: sign %s, want %s", vector.Name, sign, vector.Sign)
+		}
+		if err := Verify(publicKey, content, vector.Sign); err != nil {
+			return fmt.Errorf("vector %s: %v", vector.Name, err)
+		}
+	}
+	return nil
+}
+
+// content 向量的签名原文
+func (v *Vector) content() ([]byte, error) {
+	switch v.Kind {
+	case VectorData:
+		content, _, err := ParseData(v.Data)
+		return content, err
+	case VectorNotification:
+		return []byte(v.RespData), nil
+	}
+	return nil, fmt.Errorf("unknown vector kind %q", v.Kind)
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallRecord 一次出站汇付调用的记录
+type CallRecord struct {
+	ReqSeqID   string                 `json:"req_seq_id"`
+	ReqDate    string                 `json:"req_date"`
+	SysID      string                 `json:"sys_id"`
+	HuifuID    string                 `json:"huifu_id,omitempty"`
+	Endpoint   string                 `json:"endpoint"`
+	Params     map[string]interface{} `json:"params"`
+	Response   *NormalizedResponse    `json:"response,omitempty"`
+	Status     string                 `json:"status"` // success 或错误分类
+	Error      string                 `json:"error,omitempty"`
+	LatencyMs  int64                  `json:"latency_ms"`
+	ClientType string                 `json:"client_type"`
+	Actor      string                 `json:"actor,omitempty"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+}
+
+// CallFilter 调用记录查询条件
+type CallFilter struct {
+	HuifuID  string
+	SysID    string
+	Endpoint string
+	Status   string // success、failed 或具体错误分类
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+func (f CallFilter) matches(r *CallRecord) bool {
+	switch {
+	case f.HuifuID != "" && r.HuifuID != f.HuifuID:
+		return false
+	case f.SysID != "" && r.SysID != f.SysID:
+		return false
+	case f.Endpoint != "" && r.Endpoint != f.Endpoint:
+		return false
+	case !f.From.IsZero() && r.StartedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && r.StartedAt.After(f.To):
+		return false
+	}
+	switch f.Status {
+	case "":
+		return true
+	case "failed":
+		return r.Status != "success"
+	default:
+		return r.Status == f.Status
+	}
+}
+
+// CallJournal 出站调用日志，追加写入JSON Lines文件，并在内存中保留最近的记录供查询
+type CallJournal struct {
+	mu         sync.RWMutex
+	path       string
+	file       *os.File
+	records    []*CallRecord
+	bySeq      map[string]*CallRecord
+	inFlight   map[string]chan struct{} // 调用方req_seq_id -> 调用结束时关闭
+	maxRecords int
+}
+
+// OpenCallJournal 打开（或创建）调用日志文件并加载已有记录
+func OpenCallJournal(path string, maxRecords int) (*CallJournal, error) {
+	j := &CallJournal{
+		path:       path,
+		bySeq:      make(map[string]*CallRecord),
+		inFlight:   make(map[string]chan struct{}),
+		maxRecords: maxRecords,
+	}
+	if path == "" {
+		return j, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal file: %v", err)
+	}
+	j.file = file
+	return j, nil
+}
+
+// load 读取已有的日志文件
+func (j *CallJournal) load() error {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read journal file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record CallRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Skipping malformed journal line %d in %s: %v", line, j.path, err)
+			continue
+		}
+		j.appendLocked(&record)
+	}
+	return scanner.Err()
+}
+
+// Append 追加一条记录
+func (j *CallJournal) Append(record *CallRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.appendLocked(record)
+	if j.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal call record: %v", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write call record: %v", err)
+	}
+	return nil
+}
+
+func (j *CallJournal) appendLocked(record *CallRecord) {
+	j.records = append(j.records, record)
+	if record.ReqSeqID != "" {
+		j.bySeq[record.ReqSeqID] = record
+	}
+	if j.maxRecords > 0 && len(j.records) > j.maxRecords {
+		evicted := j.records[:len(j.records)-j.maxRecords]
+		for _, old := range evicted {
+			if j.bySeq[old.ReqSeqID] == old {
+				delete(j.bySeq, old.ReqSeqID)
+			}
+		}
+		j.records = append([]*CallRecord(nil), j.records[len(evicted):]...)
+	}
+}
+
+// Get 按req_seq_id查找记录
+func (j *CallJournal) Get(reqSeqID string) (*CallRecord, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	record, exists := j.bySeq[reqSeqID]
+	return record, exists
+}
+
+// ReqSeqConflictError 调用方提供的req_seq_id已被另一笔不同的请求使用
+type ReqSeqConflictError struct {
+	ReqSeqID string
+	Reason   string
+}
+
+func (e *ReqSeqConflictError) Error() string {
+	return fmt.Sprintf("req_seq_id %s already used: %s", e.ReqSeqID, e.Reason)
+}
+
+// Reserve 在调用汇付前占用调用方提供的req_seq_id
+// 已有结果时直接返回或报告冲突；同一流水号正在调用时等待其结束后再判断，保证同一流水号同时只有一笔请求发往汇付。
+// 返回的release须在调用结果写入日志后调用
+func (j *CallJournal) Reserve(ctx context.Context, sysID, endpoint string, params map[string]interface{}) (map[string]interface{}, func(), error) {
+	reqSeqID := stringField(params, "req_seq_id")
+	for {
+		j.mu.Lock()
+		if done, busy := j.inFlight[reqSeqID]; busy {
+			j.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+
+		replayed, err := j.replayLocked(sysID, endpoint, params)
+		if err != nil || replayed != nil {
+			j.mu.Unlock()
+			return replayed, nil, err
+		}
+
+		done := make(chan struct{})
+		j.inFlight[reqSeqID] = done
+		j.mu.Unlock()
+
+		release := func() {
+			j.mu.Lock()
+			delete(j.inFlight, reqSeqID)
+			j.mu.Unlock()
+			close(done)
+		}
+		return nil, release, nil
+	}
+}
+
+// replayLocked 查找调用方提供的req_seq_id对应的已有结果
+// 请求与记录一致且汇付已给出响应时返回存储的结果；请求不一致时返回 *ReqSeqConflictError；
+// 记录不存在或上次未得到汇付响应（如网络失败）时返回 (nil, nil)，由调用方以同一流水号重新发起；调用方需持有锁
+func (j *CallJournal) replayLocked(sysID, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	reqSeqID := stringField(params, "req_seq_id")
+	record, exists := j.bySeq[reqSeqID]
+	if !exists {
+		return nil, nil
+	}
+
+	switch {
+	case record.SysID != sysID:
+		return nil, &ReqSeqConflictError{ReqSeqID: reqSeqID, Reason: "used by another sys_id"}
+	case record.Endpoint != endpoint:
+		return nil, &ReqSeqConflictError{ReqSeqID: reqSeqID, Reason: fmt.Sprintf("used for %s", record.Endpoint)}
+	}
+	if reqDate := stringField(params, "req_date"); reqDate != "" && reqDate != record.ReqDate {
+		return nil, &ReqSeqConflictError{ReqSeqID: reqSeqID, Reason: fmt.Sprintf("used with req_date %s", record.ReqDate)}
+	}
+	if !sameParams(record.Params, redactParams(params)) {
+		return nil, &ReqSeqConflictError{ReqSeqID: reqSeqID, Reason: "used with different parameters"}
+	}
+
+	if record.Response == nil {
+		return nil, nil
+	}
+	data := make(map[string]interface{}, len(record.Response.Data))
+	for k, v := range record.Response.Data {
+		data[k] = v
+	}
+	return map[string]interface{}{
+		"resp_code": record.Response.RespCode,
+		"resp_desc": record.Response.RespDesc,
+		"data":      data,
+		"replayed":  true,
+	}, nil
+}
+
+// sameParams 比较两组参数（忽略流水号字段），以规范化JSON判断是否一致
+func sameParams(a, b map[string]interface{}) bool {
+	canonical := func(params map[string]interface{}) string {
+		stripped := make(map[string]interface{}, len(params))
+		for k, v := range params {
+			if k != "req_seq_id" && k != "req_date" {
+				stripped[k] = v
+			}
+		}
+		data, _ := json.Marshal(stripped)
+		return string(data)
+	}
+	return canonical(a) == canonical(b)
+}
+
+// Query 按条件查询记录，按时间倒序
+func (j *CallJournal) Query(filter CallFilter) []*CallRecord {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var results []*CallRecord
+	for i := len(j.records) - 1; i >= 0; i-- {
+		if !filter.matches(j.records[i]) {
+			continue
+		}
+		results = append(results, j.records[i])
+		if filter.Limit > 0 && len(results) >= filter.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// Close 关闭日志文件
+func (j *CallJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// openDefaultJournal 按 HUIFU_JOURNAL_FILE 打开调用日志（默认 ./data/call_journal.jsonl）
+// 无法打开文件时退化为仅内存记录
+func openDefaultJournal() *CallJournal {
+	path := os.Getenv("HUIFU_JOURNAL_FILE")
+	if path == "" {
+		path = "./data/call_journal.jsonl"
+	}
+	journal, err := OpenCallJournal(path, 10000)
+	if err != nil {
+		log.Printf("Failed to open call journal %s: %v, keeping records in memory only", path, err)
+		journal, _ = OpenCallJournal("", 10000)
+	}
+	return journal
+}
+
+var callJournal = openDefaultJournal()
+
+// JournalMiddleware 记录每次逻辑调用，req_seq_id 在此确定并传递给内层（含重试）
+// 调用方自带的req_seq_id若已有记录，则直接返回记录中的结果而不再请求汇付；
+// 同一流水号的并发请求排队，后到的请求在前一笔结束后重放其结果
+func JournalMiddleware(sysID string, journal *CallJournal) Middleware {
+	return func(next HuifuClient) HuifuClient {
+		kind := clientType(next)
+		return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+			if stringField(params, "req_seq_id") != "" {
+				replayed, release, err := journal.Reserve(ctx, sysID, endpoint, params)
+				if err != nil {
+					return nil, err
+				}
+				if replayed != nil {
+					log.Printf("Replaying stored result: sys_id=%s endpoint=%s req_seq_id=%s",
+						sysID, endpoint, stringField(params, "req_seq_id"))
+					return replayed, nil
+				}
+				defer release()
+			}
+
+			params = withStableReqSeq(params)
+			record := &CallRecord{
+				ReqSeqID:   stringField(params, "req_seq_id"),
+				ReqDate:    stringField(params, "req_date"),
+				SysID:      sysID,
+				HuifuID:    stringField(params, "huifu_id"),
+				Endpoint:   endpoint,
+				Params:     redactParams(params),
+				ClientType: kind,
+				Actor:      ActorFromContext(ctx),
+				TraceID:    TraceIDFromContext(ctx),
+				StartedAt:  time.Now(),
+			}
+
+			result, err := next.CallAPI(ctx, endpoint, params)
+
+			record.LatencyMs = time.Since(record.StartedAt).Milliseconds()
+			outcome := err
+			if result != nil {
+				normalized := NormalizeResponse(sysID, endpoint, result)
+				record.Response = &normalized
+				if outcome == nil {
+					outcome = CheckResponse(endpoint, result)
+				}
+			}
+			record.Status = "success"
+			if outcome != nil {
+				record.Status = string(AsHuifuError(endpoint, outcome).Category)
+				record.Error = outcome.Error()
+			}
+			if appendErr := journal.Append(record); appendErr != nil {
+				log.Printf("Failed to journal call %s: %v", record.ReqSeqID, appendErr)
+			}
+
+			return result, err
+		})(next)
+	}
+}
+
+// listCalls 查询出站调用记录
+// GET /api/calls?huifu_id=&sys_id=&endpoint=&status=&from=&to=&limit=
+func listCalls(c *gin.Context) {
+	filter := CallFilter{
+		HuifuID:  c.Query("huifu_id"),
+		SysID:    c.Query("sys_id"),
+		Endpoint: c.Query("endpoint"),
+		Status:   c.Query("status"),
+		Limit:    100,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": fmt.Sprintf("invalid from: %v", err),
+		})
+		return
+	}
+	if filter.To, err = parseTimeParam(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": fmt.Sprintf("invalid to: %v", err),
+		})
+		return
+	}
+
+	records := callJournal.Query(filter)
+	c.JSON(http.StatusOK, gin.H{
+		"calls": records,
+		"count": len(records),
+	})
+}
+
+// getCall 按req_seq_id查看单次调用
+func getCall(c *gin.Context) {
+	reqSeqID := c.Param("req_seq_id")
+	record, exists := callJournal.Get(reqSeqID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Call not found",
+			"details": fmt.Sprintf("no call recorded with req_seq_id: %s", reqSeqID),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// parseTimeParam 解析RFC3339或 yyyy-MM-dd HH:mm:ss / yyyyMMdd 格式的时间
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format: %s", value)
+}
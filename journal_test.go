@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	journalSysID    = "6666000100000001"
+	journalEndpoint = "/v2/merchant/busi/config"
+)
+
+// journalParams 一笔写操作的参数
+func journalParams(reqSeqID, reqDate, huifuID string) map[string]interface{} {
+	params := map[string]interface{}{"req_seq_id": reqSeqID, "huifu_id": huifuID}
+	if reqDate != "" {
+		params["req_date"] = reqDate
+	}
+	return params
+}
+
+// journalRecord 按参数生成调用记录，response为nil表示未得到汇付响应
+func journalRecord(params map[string]interface{}, response *NormalizedResponse) *CallRecord {
+	return &CallRecord{
+		ReqSeqID: stringField(params, "req_seq_id"),
+		ReqDate:  stringField(params, "req_date"),
+		SysID:    journalSysID,
+		Endpoint: journalEndpoint,
+		Params:   redactParams(params),
+		Response: response,
+	}
+}
+
+func TestJournalReserve(t *testing.T) {
+	journal, _ := OpenCallJournal("", 100)
+	journal.Append(journalRecord(journalParams("SEQ-DONE", "20261018", "6666000100000003"), &NormalizedResponse{
+		RespCode: "00000000",
+		RespDesc: "成功",
+		Data:     map[string]interface{}{"huifu_id": "6666000100000003"},
+	}))
+	journal.Append(journalRecord(journalParams("SEQ-FAILED", "20261018", "6666000100000003"), nil))
+
+	tests := []struct {
+		name         string
+		sysID        string
+		endpoint     string
+		params       map[string]interface{}
+		wantReplay   bool
+		wantConflict string
+	}{
+		{"new req_seq_id", journalSysID, journalEndpoint, journalParams("SEQ-NEW", "20261018", "6666000100000003"), false, ""},
+		{"same request replays", journalSysID, journalEndpoint, journalParams("SEQ-DONE", "20261018", "6666000100000003"), true, ""},
+		{"replay without req_date", journalSysID, journalEndpoint, journalParams("SEQ-DONE", "", "6666000100000003"), true, ""},
+		{"another sys_id", "6666000100000009", journalEndpoint, journalParams("SEQ-DONE", "20261018", "6666000100000003"), false, "used by another sys_id"},
+		{"another endpoint", journalSysID, "/v2/merchant/basicdata/query", journalParams("SEQ-DONE", "20261018", "6666000100000003"), false, "used for " + journalEndpoint},
+		{"another req_date", journalSysID, journalEndpoint, journalParams("SEQ-DONE", "20261019", "6666000100000003"), false, "used with req_date 20261018"},
+		{"different params", journalSysID, journalEndpoint, journalParams("SEQ-DONE", "20261018", "6666000100000004"), false, "used with different parameters"},
+		{"no response is re-sent", journalSysID, journalEndpoint, journalParams("SEQ-FAILED", "20261018", "6666000100000003"), false, ""},
+		{"no response with different params", journalSysID, journalEndpoint, journalParams("SEQ-FAILED", "20261018", "6666000100000004"), false, "used with different parameters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayed, release, err := journal.Reserve(context.Background(), tt.sysID, tt.endpoint, tt.params)
+			if release != nil {
+				defer release()
+			}
+
+			if tt.wantConflict != "" {
+				var conflict *ReqSeqConflictError
+				if !errors.As(err, &conflict) || conflict.Reason != tt.wantConflict {
+					t.Fatalf("err = %v, want conflict %q", err, tt.wantConflict)
+				}
+				if release != nil || replayed != nil {
+					t.Fatal("conflict returned a reservation or a replay")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantReplay {
+				if replayed == nil || release != nil {
+					t.Fatalf("replayed = %v, release set: %v, want a replay", replayed, release != nil)
+				}
+				if replayed["resp_code"] != "00000000" || replayed["replayed"] != true {
+					t.Errorf("replayed = %v", replayed)
+				}
+				return
+			}
+			if replayed != nil || release == nil {
+				t.Fatalf("replayed = %v, release set: %v, want a reservation", replayed, release != nil)
+			}
+		})
+	}
+}
+
+// TestJournalReserveWaitsForInFlight 同一流水号正在调用时，后到的请求等待其结束后按结果重放，或在ctx结束时返回
+func TestJournalReserveWaitsForInFlight(t *testing.T) {
+	journal, _ := OpenCallJournal("", 100)
+	params := journalParams("SEQ-BUSY", "20261018", "6666000100000003")
+
+	_, release, err := journal.Reserve(context.Background(), journalSysID, journalEndpoint, params)
+	if err != nil || release == nil {
+		t.Fatalf("first Reserve: release set %v, err %v", release != nil, err)
+	}
+
+	// ctx结束时不再等待
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, waiting, err := journal.Reserve(ctx, journalSysID, journalEndpoint, params); !errors.Is(err, context.DeadlineExceeded) || waiting != nil {
+		t.Fatalf("Reserve while in flight = %v, want the context deadline", err)
+	}
+
+	type reservation struct {
+		replayed map[string]interface{}
+		release  func()
+		err      error
+	}
+	waiter := make(chan reservation, 1)
+	go func() {
+		replayed, release, err := journal.Reserve(context.Background(), journalSysID, journalEndpoint, params)
+		waiter <- reservation{replayed, release, err}
+	}()
+	select {
+	case got := <-waiter:
+		t.Fatalf("Reserve returned while the request was in flight: %v", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	journal.Append(journalRecord(params, &NormalizedResponse{RespCode: "00000000", Data: map[string]interface{}{}}))
+	release()
+
+	select {
+	case got := <-waiter:
+		if got.err != nil || got.release != nil || got.replayed["resp_code"] != "00000000" {
+			t.Fatalf("waiting Reserve = %v, release set %v, err %v, want a replay", got.replayed, got.release != nil, got.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting Reserve was not released")
+	}
+}
+
+// TestJournalReserveConcurrent 同一流水号的并发请求只有一笔发往汇付，其余按其结果重放
+func TestJournalReserveConcurrent(t *testing.T) {
+	journal, _ := OpenCallJournal("", 100)
+	params := journalParams("SEQ-RACE", "20261018", "6666000100000003")
+
+	var sent, replays atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replayed, release, err := journal.Reserve(context.Background(), journalSysID, journalEndpoint, params)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if release == nil {
+				if replayed["resp_code"] == "00000000" {
+					replays.Add(1)
+				}
+				return
+			}
+			sent.Add(1)
+			time.Sleep(5 * time.Millisecond)
+			journal.Append(journalRecord(params, &NormalizedResponse{RespCode: "00000000", Data: map[string]interface{}{}}))
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if sent.Load() != 1 || replays.Load() != 19 {
+		t.Fatalf("sent %d, replayed %d, want 1 and 19", sent.Load(), replays.Load())
+	}
+}
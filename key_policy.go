@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyStatus 私钥使用期限状态
+type KeyStatus string
+
+const (
+	KeyStatusOK       KeyStatus = "ok"       // 未超过期限，或所在环境不限制
+	KeyStatusExpiring KeyStatus = "expiring" // 距期限不足预警天数
+	KeyStatusExpired  KeyStatus = "expired"  // 已超过最长使用期限
+)
+
+// 私钥创建时间的来源
+const (
+	KeyCreatedFromRequest     = "request"     // 保存配置时提交的 key_created_at
+	KeyCreatedFromCertificate = "certificate" // 导入文件中证书的生效时间
+	KeyCreatedFromLoad        = "loaded"      // 无法获知，按首次加载时间计
+)
+
+// KeyAgePolicy 按环境的私钥最长使用期限
+type KeyAgePolicy struct {
+	MaxAge       map[string]time.Duration // 环境 -> 最长使用期限，未设置或<=0时不限制
+	WarnBefore   time.Duration            // 到期前多久开始预警
+	BlockExpired bool                     // 生产环境私钥过期后拒绝调用
+	Interval     time.Duration            // 定时检查间隔，<=0 时不启动定时检查
+}
+
+// LoadKeyAgePolicy 从环境变量加载私钥期限策略
+//
+//	HUIFU_KEY_MAX_AGE_DAYS_PRODUCTION  生产环境最长使用天数（默认365）
+//	HUIFU_KEY_MAX_AGE_DAYS_TEST        测试环境最长使用天数（默认不限制）
+//	HUIFU_KEY_WARN_DAYS                到期前预警天数（默认30）
+//	HUIFU_KEY_BLOCK_EXPIRED=true       生产环境私钥过期后拒绝调用
+//	HUIFU_KEY_CHECK_INTERVAL_SECONDS   定时检查间隔（默认3600）
+func LoadKeyAgePolicy() KeyAgePolicy {
+	policy := KeyAgePolicy{
+		MaxAge:     map[string]time.Duration{"production": 365 * 24 * time.Hour},
+		WarnBefore: 30 * 24 * time.Hour,
+		Interval:   time.Hour,
+	}
+	for _, environment := range []string{"production", "test"} {
+		name := "HUIFU_KEY_MAX_AGE_DAYS_" + strings.ToUpper(environment)
+		if _, set := os.LookupEnv(name); set {
+			policy.MaxAge[environment] = time.Duration(envInt(name)) * 24 * time.Hour
+		}
+	}
+	if v := envInt("HUIFU_KEY_WARN_DAYS"); v > 0 {
+		policy.WarnBefore = time.Duration(v) * 24 * time.Hour
+	}
+	if v, err := strconv.ParseBool(os.Getenv("HUIFU_KEY_BLOCK_EXPIRED")); err == nil {
+		policy.BlockExpired = v
+	}
+	if v := envInt("HUIFU_KEY_CHECK_INTERVAL_SECONDS"); v != 0 {
+		policy.Interval = time.Duration(v) * time.Second
+	}
+	return policy
+}
+
+// KeyAge 私钥的使用期限信息
+type KeyAge struct {
+	Environment   string    `json:"environment"`
+	CreatedAt     time.Time `json:"created_at"`
+	CreatedSource string    `json:"created_source"`
+	LoadedAt      time.Time `json:"loaded_at"`
+	AgeDays       int       `json:"age_days"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	Status        KeyStatus `json:"status"`
+}
+
+// KeyAgeEvent 私钥期限状态变化事件
+type KeyAgeEvent struct {
+	SysID      string    `json:"sys_id"`
+	From       KeyStatus `json:"from"`
+	To         KeyStatus `json:"to"`
+	Key        KeyAge    `json:"key"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// KeyExpiredError 生产环境私钥过期后拒绝调用时返回的错误
+type KeyExpiredError struct {
+	SysID     string
+	ExpiresAt time.Time
+}
+
+func (e *KeyExpiredError) Error() string {
+	return fmt.Sprintf("private key for sys_id %s expired at %s, rotate it before calling Huifu",
+		e.SysID, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// KeyAgeMonitor 跟踪每个sys_id私钥的创建及加载时间，定期按策略检查并在状态变化时发出事件
+type KeyAgeMonitor struct {
+	mu        sync.RWMutex
+	policy    KeyAgePolicy
+	keys      map[string]KeyAge
+	statuses  map[string]KeyStatus
+	events    []KeyAgeEvent
+	maxEvents int
+	onChange  []func(KeyAgeEvent)
+}
+
+// NewKeyAgeMonitor 创建私钥期限监控
+func NewKeyAgeMonitor(policy KeyAgePolicy) *KeyAgeMonitor {
+	return &KeyAgeMonitor{
+		policy:    policy,
+		keys:      make(map[string]KeyAge),
+		statuses:  make(map[string]KeyStatus),
+		maxEvents: 1000,
+	}
+}
+
+// OnChange 注册私钥期限状态变化回调
+func (m *KeyAgeMonitor) OnChange(fn func(KeyAgeEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// Track 登记或更新sys_id的私钥并立即检查
+func (m *KeyAgeMonitor) Track(config *ConfigRequest) {
+	m.mu.Lock()
+	m.keys[config.SysID] = KeyAge{
+		Environment:   config.Environment,
+		CreatedAt:     config.KeyCreatedAt,
+		CreatedSource: config.KeyCreatedSource,
+		LoadedAt:      config.KeyLoadedAt,
+	}
+	m.mu.Unlock()
+
+	m.Check(config.SysID, time.Now())
+}
+
+// Forget 删除配置时清除其私钥记录
+func (m *KeyAgeMonitor) Forget(sysID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, sysID)
+	delete(m.statuses, sysID)
+}
+
+// Start 启动定时检查，ctx结束时停止
+func (m *KeyAgeMonitor) Start(ctx context.Context) {
+	if m.policy.Interval <= 0 {
+		log.Println("Key age checks disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.policy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				m.CheckAll(now)
+			}
+		}
+	}()
+}
+
+// CheckAll 检查所有已登记的私钥
+func (m *KeyAgeMonitor) CheckAll(now time.Time) {
+	m.mu.RLock()
+	sysIDs := make([]string, 0, len(m.keys))
+	for sysID := range m.keys {
+		sysIDs = append(sysIDs, sysID)
+	}
+	m.mu.RUnlock()
+
+	for _, sysID := range sysIDs {
+		m.Check(sysID, now)
+	}
+}
+
+// Check 按策略计算sys_id私钥的状态，状态变化时记录事件并通知
+func (m *KeyAgeMonitor) Check(sysID string, now time.Time) {
+	m.mu.Lock()
+	age, exists := m.keys[sysID]
+	if !exists {
+		m.mu.Unlock()
+		return
+	}
+	age = m.evaluate(age, now)
+
+	previous, ok := m.statuses[sysID]
+	if !ok {
+		previous = KeyStatusOK
+	}
+	m.statuses[sysID] = age.Status
+	if age.Status == previous {
+		m.mu.Unlock()
+		return
+	}
+
+	event := KeyAgeEvent{
+		SysID:      sysID,
+		From:       previous,
+		To:         age.Status,
+		Key:        age,
+		OccurredAt: now,
+	}
+	m.events = append(m.events, event)
+	if len(m.events) > m.maxEvents {
+		m.events = m.events[len(m.events)-m.maxEvents:]
+	}
+	callbacks := append([]func(KeyAgeEvent){}, m.onChange...)
+	m.mu.Unlock()
+
+	log.Printf("Key age changed: sys_id=%s %s -> %s (age %d days, expires %s)",
+		sysID, previous, age.Status, age.AgeDays, age.ExpiresAt.Format(time.RFC3339))
+	for _, fn := range callbacks {
+		fn(event)
+	}
+}
+
+// Age 返回sys_id私钥当前的期限信息
+func (m *KeyAgeMonitor) Age(sysID string) (KeyAge, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	age, exists := m.keys[sysID]
+	if !exists {
+		return KeyAge{}, false
+	}
+	return m.evaluate(age, time.Now()), true
+}
+
+// Blocked 生产环境私钥已过期且策略要求拒绝调用时返回 *KeyExpiredError
+func (m *KeyAgeMonitor) Blocked(sysID string) error {
+	if !m.policy.BlockExpired {
+		return nil
+	}
+	age, exists := m.Age(sysID)
+	if !exists || age.Environment != "production" || age.Status != KeyStatusExpired {
+		return nil
+	}
+	return &KeyExpiredError{SysID: sysID, ExpiresAt: age.ExpiresAt}
+}
+
+// Events 返回私钥期限状态变化事件（按时间倒序），sysID为空时返回全部
+func (m *KeyAgeMonitor) Events(sysID string) []KeyAgeEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := []KeyAgeEvent{}
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if sysID == "" || m.events[i].SysID == sysID {
+			events = append(events, m.events[i])
+		}
+	}
+	return events
+}
+
+// evaluate 计算私钥年龄、到期时间及状态
+func (m *KeyAgeMonitor) evaluate(age KeyAge, now time.Time) KeyAge {
+	age.AgeDays = int(now.Sub(age.CreatedAt) / (24 * time.Hour))
+	age.Status = KeyStatusOK
+	age.ExpiresAt = time.Time{}
+
+	maxAge := m.policy.MaxAge[age.Environment]
+	if maxAge <= 0 {
+		return age
+	}
+	age.ExpiresAt = age.CreatedAt.Add(maxAge)
+	switch {
+	case !now.Before(age.ExpiresAt):
+		age.Status = KeyStatusExpired
+	case now.Add(m.policy.WarnBefore).After(age.ExpiresAt):
+		age.Status = KeyStatusExpiring
+	}
+	return age
+}
+
+// checkKeyCreatedAt 拒绝晚于当前时间的 key_created_at，避免借此延长私钥使用期限
+func checkKeyCreatedAt(createdAt, now time.Time) error {
+	if createdAt.After(now) {
+		return &ValidationError{Field: "key_created_at", Reason: "must not be in the future"}
+	}
+	return nil
+}
+
+// stampKeyDates 记录私钥的加载时间并确定创建时间
+// 已登记的私钥（按公钥指纹，见 KeyRegistry）沿用登记的创建时间，提交的 key_created_at 只能更早，晚于登记时间时返回 ValidationError；
+// 未登记的私钥依次取：提交的 key_created_at、证书生效时间、私钥引用的元数据，均无时按首次加载时间计，并登记
+func stampKeyDates(config *ConfigRequest, now time.Time) error {
+	if err := checkKeyCreatedAt(config.KeyCreatedAt, now); err != nil {
+		return err
+	}
+	config.KeyLoadedAt = now
+
+	fingerprint := keyFingerprint(config.PublicKey)
+	registered, known := keyRegistry.Lookup(fingerprint)
+	if known && config.KeyCreatedAt.After(registered.CreatedAt) {
+		return &ValidationError{
+			Field:  "key_created_at",
+			Reason: fmt.Sprintf("must not be later than %s, the creation date recorded when this key was first registered", registered.CreatedAt.Format(time.RFC3339)),
+		}
+	}
+
+	candidate := KeyRecord{Fingerprint: fingerprint, SysID: config.SysID, FirstSeenAt: now}
+	switch {
+	case !config.KeyCreatedAt.IsZero():
+		candidate.CreatedAt, candidate.CreatedSource = config.KeyCreatedAt, KeyCreatedFromRequest
+	case known:
+		config.KeyCreatedAt, config.KeyCreatedSource = registered.CreatedAt, registered.CreatedSource
+		return nil
+	case config.Certificate != nil:
+		candidate.CreatedAt, candidate.CreatedSource = config.Certificate.NotBefore, KeyCreatedFromCertificate
+	case config.RSAPrivateKeyURI != "":
+		if createdAt, ok := secretResolver.CreatedAt(config.RSAPrivateKeyURI); ok {
+			candidate.CreatedAt = createdAt
+			candidate.CreatedSource, _ = keySource(config)
+		}
+	}
+	if candidate.CreatedAt.IsZero() {
+		candidate.CreatedAt, candidate.CreatedSource = now, KeyCreatedFromLoad
+	}
+
+	record, err := keyRegistry.Register(candidate)
+	if err != nil {
+		return fmt.Errorf("failed to record the key creation date: %v", err)
+	}
+	config.KeyCreatedAt, config.KeyCreatedSource = record.CreatedAt, record.CreatedSource
+	return nil
+}
+
+// KeyPolicyMiddleware 生产环境私钥过期后拒绝调用（HUIFU_KEY_BLOCK_EXPIRED=true 时）
+func KeyPolicyMiddleware(sysID string, monitor *KeyAgeMonitor) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		if err := monitor.Blocked(sysID); err != nil {
+			return nil, &HuifuError{Category: CategoryAuth, Endpoint: endpoint, Err: err}
+		}
+		return next.CallAPI(ctx, endpoint, params)
+	})
+}
+
+// getKeyAgeEvents 查询私钥期限状态变化事件
+func getKeyAgeEvents(c *gin.Context) {
+	events := keyAgeMonitor.Events(c.Query("sys_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+var keyAgeMonitor = NewKeyAgeMonitor(LoadKeyAgePolicy())
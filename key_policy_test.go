@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useKeyRegistry 在测试期间替换私钥登记
+func useKeyRegistry(t *testing.T, registry *KeyRegistry) {
+	t.Helper()
+
+	saved := keyRegistry
+	keyRegistry = registry
+	t.Cleanup(func() { keyRegistry = saved })
+}
+
+func TestStampKeyDates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	registered := now.AddDate(0, -6, 0)
+
+	tests := []struct {
+		name       string
+		publicKey  string
+		submitted  time.Time
+		wantAt     time.Time
+		wantSource string
+		wantErr    bool
+	}{
+		{"new key with key_created_at", "key-b", now.AddDate(-1, 0, 0), now.AddDate(-1, 0, 0), KeyCreatedFromRequest, false},
+		{"new key without key_created_at", "key-c", time.Time{}, now, KeyCreatedFromLoad, false},
+		{"future key_created_at", "key-d", now.Add(time.Hour), time.Time{}, "", true},
+		{"registered key keeps its date", "key-a", time.Time{}, registered, KeyCreatedFromLoad, false},
+		{"registered key with the same date", "key-a", registered, registered, KeyCreatedFromLoad, false},
+		{"registered key cannot move the date later", "key-a", now.AddDate(0, -1, 0), time.Time{}, "", true},
+		{"registered key can move the date earlier", "key-a", now.AddDate(-1, 0, 0), now.AddDate(-1, 0, 0), KeyCreatedFromRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, _ := OpenKeyRegistry("")
+			registry.Register(KeyRecord{Fingerprint: keyFingerprint("key-a"), CreatedAt: registered, CreatedSource: KeyCreatedFromLoad, FirstSeenAt: registered})
+			useKeyRegistry(t, registry)
+
+			config := &ConfigRequest{SysID: "6666000100000001", PublicKey: tt.publicKey, KeyCreatedAt: tt.submitted}
+			err := stampKeyDates(config, now)
+			var validationErr *ValidationError
+			if tt.wantErr {
+				if !errors.As(err, &validationErr) || validationErr.Field != "key_created_at" {
+					t.Fatalf("err = %v, want a key_created_at validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !config.KeyCreatedAt.Equal(tt.wantAt) || config.KeyCreatedSource != tt.wantSource {
+				t.Errorf("created at %s (%s), want %s (%s)", config.KeyCreatedAt, config.KeyCreatedSource, tt.wantAt, tt.wantSource)
+			}
+			if record, _ := registry.Lookup(keyFingerprint(tt.publicKey)); !record.CreatedAt.Equal(tt.wantAt) {
+				t.Errorf("registry created at %s, want %s", record.CreatedAt, tt.wantAt)
+			}
+		})
+	}
+}
+
+// TestKeyAgeSurvivesDeleteAndRestart 删除配置后以同一私钥重新保存、或重启服务后，私钥创建时间不被重置
+func TestKeyAgeSurvivesDeleteAndRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	isolateServerState(t)
+	path := filepath.Join(t.TempDir(), "key_registry.jsonl")
+	registry, err := OpenKeyRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeyRegistry(t, registry)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	createdAt := time.Now().AddDate(0, 0, -400).UTC().Truncate(time.Second)
+
+	server := setupRouter()
+	save := func(body map[string]interface{}) contractResponse {
+		body["sys_id"], body["product_id"], body["rsa_private_key"] = contractSysID, "PAYUN", privateKey
+		return exchange(t, server, jsonBody(t, http.MethodPost, "/api/config", body))
+	}
+	assertCreatedAt := func(step string) {
+		t.Helper()
+		config, exists := configManager.GetConfig(contractSysID)
+		if !exists || !config.KeyCreatedAt.Equal(createdAt) || config.KeyCreatedSource != KeyCreatedFromRequest {
+			t.Fatalf("%s: key created at %v, want %s from the first registration", step, config, createdAt)
+		}
+	}
+
+	if got := save(map[string]interface{}{"key_created_at": createdAt.Format(time.RFC3339)}); got.status != http.StatusOK {
+		t.Fatalf("first save = %d %v", got.status, got.body)
+	}
+	assertCreatedAt("first save")
+
+	// 删除后重新保存：不提交创建时间时沿用登记的时间，提交更晚的时间被拒绝
+	exchange(t, server, httptest.NewRequest(http.MethodDelete, "/api/config/"+contractSysID, nil))
+	if got := save(map[string]interface{}{}); got.status != http.StatusOK {
+		t.Fatalf("re-save = %d %v", got.status, got.body)
+	}
+	assertCreatedAt("re-save after delete")
+
+	exchange(t, server, httptest.NewRequest(http.MethodDelete, "/api/config/"+contractSysID, nil))
+	later := time.Now().AddDate(0, 0, -1).Format(time.RFC3339)
+	if got := save(map[string]interface{}{"key_created_at": later}); got.status != http.StatusBadRequest || got.body["field"] != "key_created_at" {
+		t.Fatalf("re-save with a later key_created_at = %d %v, want 400 field key_created_at", got.status, got.body)
+	}
+
+	// 重启：重新打开登记文件
+	registry.Close()
+	reopened, err := OpenKeyRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	keyRegistry = reopened
+	if got := save(map[string]interface{}{}); got.status != http.StatusOK {
+		t.Fatalf("save after restart = %d %v", got.status, got.body)
+	}
+	assertCreatedAt("save after restart")
+}
+
+func jsonBody(t *testing.T, method, path string, body interface{}) *http.Request {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
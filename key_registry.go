@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// KeyRecord 私钥的登记记录，按公钥指纹保存，删除配置或重启后仍保留
+type KeyRecord struct {
+	Fingerprint   string    `json:"fingerprint"`
+	SysID         string    `json:"sys_id"` // 首次登记该私钥的sys_id
+	CreatedAt     time.Time `json:"created_at"`
+	CreatedSource string    `json:"created_source"`
+	FirstSeenAt   time.Time `json:"first_seen_at"`
+}
+
+// KeyRegistry 已登记私钥的创建时间，追加写入JSON Lines文件
+// 同一私钥的创建时间只能提前不能推后，删除后重新保存或重启服务都不会重置私钥的使用期限
+type KeyRegistry struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records map[string]*KeyRecord
+}
+
+// OpenKeyRegistry 打开（或创建）私钥登记文件并加载已有记录，path为空时仅在内存中保存
+func OpenKeyRegistry(path string) (*KeyRegistry, error) {
+	r := &KeyRegistry{
+		path:    path,
+		records: make(map[string]*KeyRecord),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key registry directory: %v", err)
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key registry file: %v", err)
+	}
+	r.file = file
+	return r, nil
+}
+
+// load 读取已有的登记文件，同一指纹保留最早的创建时间
+func (r *KeyRegistry) load() error {
+	file, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read key registry file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record KeyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Fingerprint == "" {
+			log.Printf("Skipping malformed key registry line %d in %s: %v", line, r.path, err)
+			continue
+		}
+		r.mergeLocked(&record)
+	}
+	return scanner.Err()
+}
+
+// mergeLocked 登记记录，已有更早或相同的创建时间时保留原记录；返回是否有变化，调用方需持有锁
+func (r *KeyRegistry) mergeLocked(record *KeyRecord) bool {
+	existing, exists := r.records[record.Fingerprint]
+	if exists && !record.CreatedAt.Before(existing.CreatedAt) {
+		return false
+	}
+	if exists {
+		record.SysID, record.FirstSeenAt = existing.SysID, existing.FirstSeenAt
+	}
+	r.records[record.Fingerprint] = record
+	return true
+}
+
+// Lookup 按公钥指纹查找登记记录
+func (r *KeyRegistry) Lookup(fingerprint string) (KeyRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, exists := r.records[fingerprint]
+	if !exists {
+		return KeyRecord{}, false
+	}
+	return *record, true
+}
+
+// Register 登记私钥，返回生效的记录：未登记过或创建时间更早时写入record，否则保留原记录
+func (r *KeyRegistry) Register(record KeyRecord) (KeyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.mergeLocked(&record) {
+		return *r.records[record.Fingerprint], nil
+	}
+	if r.file == nil {
+		return record, nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return record, fmt.Errorf("failed to marshal key record: %v", err)
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return record, fmt.Errorf("failed to write key record: %v", err)
+	}
+	return record, nil
+}
+
+// Close 关闭登记文件
+func (r *KeyRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// keyFingerprint 公钥（base64 PKIX DER）的SHA-256，十六进制
+func keyFingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(publicKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// openDefaultKeyRegistry 按 HUIFU_KEY_REGISTRY_FILE 打开私钥登记文件（默认 ./data/key_registry.jsonl）
+// 无法打开文件时退化为仅内存记录
+func openDefaultKeyRegistry() *KeyRegistry {
+	path := os.Getenv("HUIFU_KEY_REGISTRY_FILE")
+	if path == "" {
+		path = "./data/key_registry.jsonl"
+	}
+	registry, err := OpenKeyRegistry(path)
+	if err != nil {
+		log.Printf("Failed to open key registry %s: %v, key creation dates will not survive a restart", path, err)
+		registry, _ = OpenKeyRegistry("")
+	}
+	return registry
+}
+
+var keyRegistry = openDefaultKeyRegistry()
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware 包装HuifuClient的装饰器
+type Middleware func(next HuifuClient) HuifuClient
+
+// Interceptor 拦截一次调用，可在调用next前后加入横切逻辑
+type Interceptor func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error)
+
+// Chain 按顺序组装中间件，第一个中间件位于最外层
+func Chain(client HuifuClient, middlewares ...Middleware) HuifuClient {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		client = middlewares[i](client)
+	}
+	return client
+}
+
+// Intercept 将拦截函数转换为中间件
+func Intercept(interceptor Interceptor) Middleware {
+	return func(next HuifuClient) HuifuClient {
+		return &interceptedClient{next: next, intercept: interceptor}
+	}
+}
+
+// interceptedClient 由拦截函数构成的装饰层
+type interceptedClient struct {
+	next      HuifuClient
+	intercept Interceptor
+}
+
+func (c *interceptedClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	return c.intercept(ctx, endpoint, params, c.next)
+}
+
+// Unwrap 返回被包装的客户端
+func (c *interceptedClient) Unwrap() HuifuClient {
+	return c.next
+}
+
+// clientType 返回最内层客户端的类型名称
+func clientType(client HuifuClient) string {
+	switch unwrapClient(client).(type) {
+	case *RealHuifuClient:
+		return "real"
+	case *MockHuifuClient:
+		return "mock"
+	case *SignerHuifuClient:
+		return "signer"
+	case *ReplayHuifuClient:
+		return "replay"
+	default:
+		return "unknown"
+	}
+}
+
+// buildClientChain 为sys_id组装客户端中间件链
+//
+//	tracing → journal → audit → metrics → key policy → retry → rate limit → breaker → logging → client
+//
+// 重试以内的各层对每次尝试分别生效，以外的各层按一次逻辑调用计
+func buildClientChain(sysID string, client HuifuClient) HuifuClient {
+	return Chain(client,
+		TracingMiddleware(sysID),
+		JournalMiddleware(sysID, callJournal),
+		AuditMiddleware(sysID, auditLog),
+		MetricsMiddleware(sysID, callMetrics),
+		KeyPolicyMiddleware(sysID, keyAgeMonitor),
+		RetryMiddleware(DefaultRetryPolicy()),
+		RateLimitMiddleware(sysID, rateLimiter),
+		BreakerMiddleware(sysID, breakerRegistry),
+		LoggingMiddleware(sysID),
+	)
+}
+
+// buildProbeChain 为健康检查组装客户端中间件链
+//
+//	tracing → key policy → breaker → logging → client
+//
+// 定时探测不写入调用流水、审计及调用统计，不重试也不占用商户的限流额度，
+// 避免挤占流水中的业务记录；私钥过期及熔断仍反映在检查结果中
+func buildProbeChain(sysID string, client HuifuClient) HuifuClient {
+	return Chain(client,
+		TracingMiddleware(sysID),
+		KeyPolicyMiddleware(sysID, keyAgeMonitor),
+		BreakerMiddleware(sysID, breakerRegistry),
+		LoggingMiddleware(sysID),
+	)
+}
+
+// RetryMiddleware 重试中间件
+func RetryMiddleware(policy RetryPolicy) Middleware {
+	return func(next HuifuClient) HuifuClient {
+		return NewRetryingClient(next, policy)
+	}
+}
+
+// BreakerMiddleware 熔断中间件
+func BreakerMiddleware(sysID string, registry *BreakerRegistry) Middleware {
+	return func(next HuifuClient) HuifuClient {
+		return NewBreakerClient(next, sysID, registry)
+	}
+}
+
+// sensitiveParamMarkers 参数名包含这些片段时在日志中脱敏
+var sensitiveParamMarkers = []string{"key", "secret", "sign", "password", "token", "cert"}
+
+// redactParams 复制参数并对敏感字段脱敏
+func redactParams(params map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		lower := strings.ToLower(k)
+		sensitive := false
+		for _, marker := range sensitiveParamMarkers {
+			if strings.Contains(lower, marker) {
+				sensitive = true
+				break
+			}
+		}
+		switch {
+		case sensitive:
+			redacted[k] = "***"
+		case isMap(v):
+			redacted[k] = redactParams(v.(map[string]interface{}))
+		default:
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+func isMap(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// LoggingMiddleware 脱敏日志中间件
+func LoggingMiddleware(sysID string) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		start := time.Now()
+		log.Printf("CallAPI start: trace_id=%s sys_id=%s endpoint=%s params=%v",
+			TraceIDFromContext(ctx), sysID, endpoint, redactParams(params))
+
+		result, err := next.CallAPI(ctx, endpoint, params)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			log.Printf("CallAPI failed: trace_id=%s sys_id=%s endpoint=%s elapsed=%v: %v",
+				TraceIDFromContext(ctx), sysID, endpoint, elapsed, err)
+			return nil, err
+		}
+
+		data := responseData(result)
+		log.Printf("CallAPI done: trace_id=%s sys_id=%s endpoint=%s elapsed=%v resp_code=%s resp_desc=%s",
+			TraceIDFromContext(ctx), sysID, endpoint, elapsed, stringField(data, "resp_code"), stringField(data, "resp_desc"))
+		return result, nil
+	})
+}
+
+type traceIDKey struct{}
+
+// WithTraceID 将trace_id写入context
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext 读取context中的trace_id
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// newTraceID 生成随机trace/span id
+func newTraceID(n int) string {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(raw)
+}
+
+// TracingMiddleware 为每次调用建立span，上游未携带trace_id时新建
+func TracingMiddleware(sysID string) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		traceID := TraceIDFromContext(ctx)
+		if traceID == "" {
+			traceID = newTraceID(16)
+			ctx = WithTraceID(ctx, traceID)
+		}
+		spanID := newTraceID(8)
+		start := time.Now()
+
+		result, err := next.CallAPI(ctx, endpoint, params)
+
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		log.Printf("span: trace_id=%s span_id=%s name=huifu%s sys_id=%s duration=%v status=%s",
+			traceID, spanID, endpoint, sysID, time.Since(start).Round(time.Millisecond), status)
+		return result, err
+	})
+}
+
+// requestTracing gin中间件：从 X-Request-ID 读取或生成trace_id并写入请求context
+func requestTracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID := c.GetHeader("X-Request-ID")
+		if traceID == "" {
+			traceID = newTraceID(16)
+		}
+		c.Request = c.Request.WithContext(WithTraceID(c.Request.Context(), traceID))
+		c.Header("X-Request-ID", traceID)
+		c.Next()
+	}
+}
+
+// RateLimiter 按sys_id的令牌桶限流
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // 每秒补充的令牌数，<=0 表示不限流
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Wait 等待获取令牌，context结束时返回错误
+func (l *RateLimiter) Wait(ctx context.Context, sysID string) error {
+	for {
+		delay := l.reserve(sysID)
+		if delay == 0 {
+			return nil
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return fmt.Errorf("rate limit wait for sys_id %s interrupted: %w", sysID, err)
+		}
+	}
+}
+
+// reserve 尝试取一个令牌，返回需要等待的时间（0表示已取得）
+func (l *RateLimiter) reserve(sysID string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	bucket, exists := l.buckets[sysID]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[sysID] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0
+	}
+	return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// LoadRateLimiter 从环境变量 HUIFU_RATE_LIMIT（每秒请求数）与 HUIFU_RATE_BURST 创建限流器
+func LoadRateLimiter() *RateLimiter {
+	return NewRateLimiter(float64(envInt("HUIFU_RATE_LIMIT")), envInt("HUIFU_RATE_BURST"))
+}
+
+var rateLimiter = LoadRateLimiter()
+
+// RateLimitMiddleware 限流中间件
+func RateLimitMiddleware(sysID string, limiter *RateLimiter) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		if err := limiter.Wait(ctx, sysID); err != nil {
+			return nil, err
+		}
+		return next.CallAPI(ctx, endpoint, params)
+	})
+}
+
+// CallMetrics 调用计数与耗时统计
+type CallMetrics struct {
+	mu      sync.Mutex
+	entries map[breakerKey]*callMetric
+}
+
+type callMetric struct {
+	Calls        int64
+	Errors       int64
+	ByCategory   map[string]int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// NewCallMetrics 创建调用统计
+func NewCallMetrics() *CallMetrics {
+	return &CallMetrics{entries: make(map[breakerKey]*callMetric)}
+}
+
+// Observe 记录一次调用
+func (m *CallMetrics) Observe(sysID, endpoint string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := breakerKey{sysID: sysID, endpoint: endpoint}
+	metric, exists := m.entries[key]
+	if !exists {
+		metric = &callMetric{ByCategory: make(map[string]int64)}
+		m.entries[key] = metric
+	}
+
+	metric.Calls++
+	metric.TotalLatency += latency
+	if latency > metric.MaxLatency {
+		metric.MaxLatency = latency
+	}
+	if err != nil {
+		metric.Errors++
+		metric.ByCategory[string(AsHuifuError(endpoint, err).Category)]++
+	}
+}
+
+// Snapshot 返回统计快照
+func (m *CallMetrics) Snapshot() []gin.H {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]gin.H, 0, len(m.entries))
+	for key, metric := range m.entries {
+		avg := time.Duration(0)
+		if metric.Calls > 0 {
+			avg = metric.TotalLatency / time.Duration(metric.Calls)
+		}
+		byCategory := make(map[string]int64, len(metric.ByCategory))
+		for k, v := range metric.ByCategory {
+			byCategory[k] = v
+		}
+		snapshot = append(snapshot, gin.H{
+			"sys_id":         key.sysID,
+			"endpoint":       key.endpoint,
+			"calls":          metric.Calls,
+			"errors":         metric.Errors,
+			"by_category":    byCategory,
+			"avg_latency_ms": avg.Milliseconds(),
+			"max_latency_ms": metric.MaxLatency.Milliseconds(),
+		})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		a, b := snapshot[i], snapshot[j]
+		if a["sys_id"] != b["sys_id"] {
+			return a["sys_id"].(string) < b["sys_id"].(string)
+		}
+		return a["endpoint"].(string) < b["endpoint"].(string)
+	})
+	return snapshot
+}
+
+var callMetrics = NewCallMetrics()
+
+// MetricsMiddleware 调用统计中间件，业务失败同样计为错误
+func MetricsMiddleware(sysID string, metrics *CallMetrics) Middleware {
+	return Intercept(func(ctx context.Context, endpoint string, params map[string]interface{}, next HuifuClient) (map[string]interface{}, error) {
+		start := time.Now()
+		result, err := next.CallAPI(ctx, endpoint, params)
+
+		observed := err
+		if observed == nil {
+			observed = CheckResponse(endpoint, result)
+		}
+		metrics.Observe(sysID, endpoint, time.Since(start), observed)
+		return result, err
+	})
+}
+
+// getMetrics 查询调用统计
+func getMetrics(c *gin.Context) {
+	snapshot := callMetrics.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"metrics": snapshot,
+		"count":   len(snapshot),
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockRespMerchantNotFound 模拟客户端对未知商户返回的响应码
+const mockRespMerchantNotFound = "10000005"
+
+// MockMerchantState 模拟客户端中单个商户（huifu_id）的状态
+type MockMerchantState struct {
+	HuifuID    string             `json:"huifu_id"`
+	WxConfList []WeChatConfigItem `json:"wx_conf_list"`
+}
+
+// MockStateStore 模拟客户端共享的商户状态，使写入的微信配置对后续查询可见
+// 已知商户包括：预置的商户及已配置的sys_id，对未知商户的查询和写入均返回商户不存在
+type MockStateStore struct {
+	mu        sync.RWMutex
+	merchants map[string]*MockMerchantState
+}
+
+// NewMockStateStore 创建模拟状态存储
+func NewMockStateStore() *MockStateStore {
+	return &MockStateStore{
+		merchants: make(map[string]*MockMerchantState),
+	}
+}
+
+// Seed 预置商户状态，已存在的商户被整体替换
+func (s *MockStateStore) Seed(merchants []MockMerchantState) error {
+	for _, merchant := range merchants {
+		if err := validateHuifuID(merchant.HuifuID); err != nil {
+			return err
+		}
+		for _, item := range merchant.WxConfList {
+			if err := item.Validate(); err != nil {
+				return fmt.Errorf("huifu_id %s: %w", merchant.HuifuID, err)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, merchant := range merchants {
+		seeded := &MockMerchantState{
+			HuifuID:    merchant.HuifuID,
+			WxConfList: append([]WeChatConfigItem{}, merchant.WxConfList...),
+		}
+		s.merchants[merchant.HuifuID] = seeded
+	}
+	return nil
+}
+
+// Reset 清除指定商户的状态，huifuID为空时清除全部
+func (s *MockStateStore) Reset(huifuID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if huifuID == "" {
+		s.merchants = make(map[string]*MockMerchantState)
+		return
+	}
+	delete(s.merchants, huifuID)
+}
+
+// Lookup 返回商户状态的副本
+func (s *MockStateStore) Lookup(huifuID string) (MockMerchantState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	merchant, exists := s.merchants[huifuID]
+	if !exists {
+		return MockMerchantState{}, false
+	}
+	return MockMerchantState{
+		HuifuID:    merchant.HuifuID,
+		WxConfList: append([]WeChatConfigItem{}, merchant.WxConfList...),
+	}, true
+}
+
+// Ensure 登记商户（已存在时不变）
+func (s *MockStateStore) Ensure(huifuID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.merchants[huifuID]; !exists {
+		s.merchants[huifuID] = &MockMerchantState{HuifuID: huifuID}
+	}
+}
+
+// ApplyConfig 写入一条微信配置：相同fee_type与AppID的配置被更新，否则追加
+// 商户未知时不写入并返回false
+func (s *MockStateStore) ApplyConfig(huifuID string, item WeChatConfigItem) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merchant, exists := s.merchants[huifuID]
+	if !exists {
+		return false
+	}
+	for i, existing := range merchant.WxConfList {
+		if existing.FeeType == item.FeeType &&
+			existing.WxWoaAppID == item.WxWoaAppID &&
+			existing.WxAppletAppID == item.WxAppletAppID &&
+			existing.WxSubscribeAppID == item.WxSubscribeAppID {
+			merchant.WxConfList[i] = item
+			return true
+		}
+	}
+	merchant.WxConfList = append(merchant.WxConfList, item)
+	return true
+}
+
+// Snapshot 返回全部商户状态，按huifu_id排序
+func (s *MockStateStore) Snapshot() []MockMerchantState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	merchants := make([]MockMerchantState, 0, len(s.merchants))
+	for _, merchant := range s.merchants {
+		merchants = append(merchants, MockMerchantState{
+			HuifuID:    merchant.HuifuID,
+			WxConfList: append([]WeChatConfigItem{}, merchant.WxConfList...),
+		})
+	}
+	sort.Slice(merchants, func(i, j int) bool { return merchants[i].HuifuID < merchants[j].HuifuID })
+	return merchants
+}
+
+var mockState = NewMockStateStore()
+
+// mockMerchantNotFound 未知商户的模拟响应
+func mockMerchantNotFound(huifuID string) map[string]interface{} {
+	return map[string]interface{}{
+		"resp_code": mockRespMerchantNotFound,
+		"resp_desc": "商户不存在",
+		"data": map[string]interface{}{
+			"huifu_id": huifuID,
+		},
+	}
+}
+
+// getMockState 查看模拟客户端的商户状态
+func getMockState(c *gin.Context) {
+	merchants := mockState.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"merchants": merchants,
+		"count":     len(merchants),
+	})
+}
+
+// seedMockState 预置模拟客户端的商户状态
+func seedMockState(c *gin.Context) {
+	var req struct {
+		Merchants []MockMerchantState `json:"merchants" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := mockState.Seed(req.Merchants); err != nil {
+		writeCallError(c, "Invalid mock state", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Mock state seeded",
+		"count":   len(req.Merchants),
+	})
+}
+
+// resetMockState 清除模拟客户端的商户状态，?huifu_id= 时仅清除该商户
+func resetMockState(c *gin.Context) {
+	huifuID := c.Query("huifu_id")
+	mockState.Reset(huifuID)
+
+	// 已配置的sys_id始终是已知商户
+	for _, sysID := range configManager.SysIDs() {
+		if client, err := configManager.GetSDKClient(sysID); err == nil {
+			if _, ok := unwrapClient(client).(*MockHuifuClient); ok {
+				mockState.Ensure(sysID)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Mock state reset",
+		"huifu_id": huifuID,
+	})
+}
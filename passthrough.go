@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EndpointPermission 接口的权限级别
+type EndpointPermission string
+
+const (
+	// PermissionRead 只读查询，白名单放行即可调用
+	PermissionRead EndpointPermission = "read"
+	// PermissionWrite 写操作，需操作员标识，生产环境还需TOTP二次验证
+	PermissionWrite EndpointPermission = "write"
+)
+
+// NormalizedResponse 统一格式的汇付响应
+type NormalizedResponse struct {
+	Endpoint string                 `json:"endpoint"`
+	SysID    string                 `json:"sys_id"`
+	RespCode string                 `json:"resp_code"`
+	RespDesc string                 `json:"resp_desc"`
+	ReqSeqID string                 `json:"req_seq_id,omitempty"`
+	Replayed bool                   `json:"replayed,omitempty"` // 按已有req_seq_id返回的存储结果
+	Data     map[string]interface{} `json:"data"`
+}
+
+// NormalizeResponse 将CallAPI结果转换为统一格式
+func NormalizeResponse(sysID, endpoint string, result map[string]interface{}) NormalizedResponse {
+	data := responseData(result)
+	return NormalizedResponse{
+		Endpoint: endpoint,
+		SysID:    sysID,
+		RespCode: firstNonEmpty(stringField(data, "resp_code"), stringField(result, "resp_code")),
+		RespDesc: firstNonEmpty(stringField(data, "resp_desc"), stringField(result, "resp_desc")),
+		ReqSeqID: stringField(data, "req_seq_id"),
+		Replayed: result["replayed"] == true,
+		Data:     data,
+	}
+}
+
+// PassthroughPolicy 通用调用接口的部署级白名单
+type PassthroughPolicy struct {
+	allowAll bool
+	allowed  map[string]bool
+}
+
+// ParsePassthroughPolicy 解析逗号分隔的接口白名单，"*" 表示放行所有已注册接口
+func ParsePassthroughPolicy(spec string) *PassthroughPolicy {
+	policy := &PassthroughPolicy{allowed: make(map[string]bool)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		switch entry {
+		case "":
+			continue
+		case "*":
+			policy.allowAll = true
+		default:
+			policy.allowed[entry] = true
+		}
+	}
+	return policy
+}
+
+// LoadPassthroughPolicy 从环境变量 HUIFU_CALL_ALLOWLIST 加载白名单，未配置时全部拒绝
+func LoadPassthroughPolicy() *PassthroughPolicy {
+	return ParsePassthroughPolicy(os.Getenv("HUIFU_CALL_ALLOWLIST"))
+}
+
+// Allows 判断接口是否在白名单内
+func (p *PassthroughPolicy) Allows(endpoint string) bool {
+	return p.allowAll || p.allowed[endpoint]
+}
+
+var passthroughPolicy = LoadPassthroughPolicy()
+
+// callEndpoint 通用汇付接口调用
+// POST /api/call/:sys_id/*endpoint，请求体即接口参数
+func callEndpoint(c *gin.Context) {
+	sysID := c.Param("sys_id")
+	endpoint := c.Param("endpoint")
+
+	spec, err := endpointRegistry.Lookup(endpoint)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Endpoint not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !passthroughPolicy.Allows(spec.Path) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Endpoint not allowed",
+			"details": fmt.Sprintf("%s is not in this deployment's call allowlist", spec.Path),
+		})
+		return
+	}
+
+	params, err := decodeParams(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := spec.ValidateParams(params); err != nil {
+		writeCallError(c, "Invalid request", err)
+		return
+	}
+
+	config, exists := configManager.GetConfig(sysID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Configuration not found",
+			"details": fmt.Sprintf("configuration not found for sys_id: %s", sysID),
+		})
+		return
+	}
+
+	if spec.Permission == PermissionWrite {
+		if strings.TrimSpace(c.GetHeader(operatorHeader)) == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Operator required",
+				"details": fmt.Sprintf("%s requires the %s header", spec.Path, operatorHeader),
+			})
+			return
+		}
+		if !requireStepUp(c, "call:"+spec.Path, sysID, config.Environment) {
+			return
+		}
+	}
+
+	client, err := configManager.GetSDKClient(sysID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Configuration not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("Passthrough call: sys_id=%s endpoint=%s operator=%s", sysID, spec.Path, c.GetHeader(operatorHeader))
+	result, err := callHuifu(c.Request.Context(), client, spec.Path, params)
+	if err != nil {
+		writeCallError(c, fmt.Sprintf("Call to %s failed", spec.Path), err)
+		return
+	}
+
+	c.JSON(http.StatusOK, NormalizeResponse(sysID, spec.Path, result))
+}
+
+// decodeParams 解码请求体为参数map，数字保留为json.Number
+func decodeParams(body io.Reader) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+
+	params := make(map[string]interface{})
+	if err := decoder.Decode(&params); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("request body must be a JSON object: %v", err)
+	}
+	return params, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"huifu-config-system/huifusign"
+)
+
+// maxKeyFileSize 上传私钥文件的最大字节数
+const maxKeyFileSize = 64 << 10
+
+// CertificateInfo 私钥文件中与私钥匹配的证书
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+// importedKey 从上传文件导入的私钥
+type importedKey struct {
+	Format      string
+	PrivateKey  string // PKCS#8 PEM
+	Certificate *CertificateInfo
+}
+
+// normalizePrivateKey 校验商户私钥并统一为PKCS#8 PEM
+// 接受PKCS#1/PKCS#8 PEM及不带标记的base64，格式错误或非RSA私钥返回 rsa_private_key 字段的校验错误
+func normalizePrivateKey(raw string) (string, error) {
+	key, err := huifusign.ParsePrivateKey(raw)
+	if err != nil {
+		return "", &ValidationError{Field: "rsa_private_key", Reason: err.Error()}
+	}
+	return huifusign.EncodePrivateKeyPEM(key)
+}
+
+// sdkPrivateKey 转换为SDK配置中 rsa_merch_private_key 的格式：不带标记、不换行的base64 PKCS#8
+func sdkPrivateKey(privateKey string) (string, error) {
+	key, err := huifusign.ParsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return huifusign.EncodePrivateKey(key)
+}
+
+// derivePublicKey 由私钥导出公钥，格式为不带标记的base64 PKIX DER，即汇付控制台登记公钥的格式
+func derivePublicKey(privateKey string) (string, error) {
+	key, err := huifusign.ParsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return huifusign.EncodePublicKey(&key.PublicKey)
+}
+
+// readKeyFile 读取上传的 key_file 并用口令解密，解密结果只保存在内存中
+func readKeyFile(c *gin.Context, passphrase string) (*importedKey, error) {
+	header, err := c.FormFile("key_file")
+	if err != nil {
+		return nil, &ValidationError{Field: "key_file", Reason: "is required"}
+	}
+	if header.Size > maxKeyFileSize {
+		return nil, &ValidationError{Field: "key_file", Reason: fmt.Sprintf("file is larger than %d bytes", maxKeyFileSize)}
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, &ValidationError{Field: "key_file", Reason: err.Error()}
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxKeyFileSize))
+	if err != nil {
+		return nil, &ValidationError{Field: "key_file", Reason: err.Error()}
+	}
+
+	bundle, err := huifusign.ParseKeyBundle(data, passphrase)
+	if err != nil {
+		if errors.Is(err, huifusign.ErrIncorrectPassphrase) {
+			return nil, &ValidationError{Field: "passphrase", Reason: err.Error()}
+		}
+		return nil, &ValidationError{Field: "key_file", Reason: err.Error()}
+	}
+
+	imported := &importedKey{Format: bundle.Format}
+	if cert := bundle.Certificate(); cert != nil {
+		if err := checkCertificateValidity(cert, time.Now()); err != nil {
+			return nil, &ValidationError{Field: "key_file", Reason: err.Error()}
+		}
+		imported.Certificate = &CertificateInfo{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+		}
+	}
+	if imported.PrivateKey, err = huifusign.EncodePrivateKeyPEM(bundle.PrivateKey); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// checkCertificateValidity 证书须在有效期内
+func checkCertificateValidity(cert *x509.Certificate, now time.Time) error {
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("certificate %q is not valid until %s", cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %q expired at %s", cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// getPublicKey 返回配置私钥对应的公钥
+// GET /api/config/:sys_id/public-key
+func getPublicKey(c *gin.Context) {
+	sysID := c.Param("sys_id")
+	config, exists := configManager.GetConfig(sysID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Configuration not found",
+			"details": fmt.Sprintf("configuration not found for sys_id: %s", sysID),
+		})
+		return
+	}
+
+	key, err := huifusign.ParsePublicKey(config.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to derive public key",
+			"details": err.Error(),
+		})
+		return
+	}
+	der, _ := base64.StdEncoding.DecodeString(config.PublicKey)
+	fingerprint := sha256.Sum256(der)
+
+	c.JSON(http.StatusOK, gin.H{
+		"sys_id":      sysID,
+		"public_key":  config.PublicKey,
+		"pem":         string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		"key_bits":    key.N.BitLen(),
+		"fingerprint": hex.EncodeToString(fingerprint[:]),
+	})
+}
+
+// matchPublicKey 校验公钥是否与配置的私钥匹配，公钥可为PEM或base64（PKIX或PKCS#1）
+// POST /api/config/:sys_id/public-key/match，请求体为 {"public_key": "..."}
+func matchPublicKey(c *gin.Context) {
+	var req struct {
+		PublicKey string `json:"public_key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sysID := c.Param("sys_id")
+	config, exists := configManager.GetConfig(sysID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Configuration not found",
+			"details": fmt.Sprintf("configuration not found for sys_id: %s", sysID),
+		})
+		return
+	}
+
+	candidate, err := huifusign.ParsePublicKey(req.PublicKey)
+	if err != nil {
+		writeCallError(c, "Invalid request", &ValidationError{Field: "public_key", Reason: err.Error()})
+		return
+	}
+	stored, err := huifusign.ParsePublicKey(config.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to derive public key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sys_id": sysID,
+		"match":  stored.Equal(candidate),
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestImportStepUpBeforeDecrypting 生产环境导入未通过二次验证时，在解析私钥文件之前即被拒绝
+func TestImportStepUpBeforeDecrypting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	isolateServerState(t)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("sys_id", contractImport)
+	form.WriteField("product_id", "PAYUN")
+	form.WriteField("environment", "production")
+	form.WriteField("passphrase", "secret")
+	part, _ := form.CreateFormFile("key_file", "merchant.p12")
+	part.Write([]byte("not a key file"))
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/config/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	got := exchange(t, setupRouter(), req)
+	if got.status != http.StatusForbidden || got.body["error"] != "Step-up verification required" {
+		t.Fatalf("import = %d %v, want 403 step-up before the key file is parsed", got.status, got.body)
+	}
+}
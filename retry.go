@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（含首次）
+	BaseDelay   time.Duration // 首次重试前的等待时间
+	MaxDelay    time.Duration // 单次等待上限
+	Jitter      float64       // 抖动比例 0~1，实际等待在 [delay*(1-Jitter), delay] 之间
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Jitter:      0.5,
+	}
+}
+
+// backoff 计算第n次重试（从1开始）前的等待时间
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << uint(retry-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// IsRetryable 判断错误是否可以重试
+// 仅网络类错误可重试；业务拒绝、验签、权限、熔断及调用方取消均不重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var validationErr *ValidationError
+	var openErr *CircuitOpenError
+	var conflictErr *ReqSeqConflictError
+	if errors.As(err, &validationErr) || errors.As(err, &openErr) || errors.As(err, &conflictErr) {
+		return false
+	}
+	return AsHuifuError("", err).Category == CategoryNetwork
+}
+
+// StatusCheckFunc 查询一次写操作是否已在汇付侧生效
+// applied为true时result作为该次调用的结果返回，不再重试
+type StatusCheckFunc func(ctx context.Context, client HuifuClient, params map[string]interface{}) (applied bool, result map[string]interface{}, err error)
+
+// RetryingClient 为HuifuClient增加重试能力
+// 同一次逻辑调用的所有尝试使用相同的 req_seq_id/req_date，避免汇付侧重复受理
+type RetryingClient struct {
+	next   HuifuClient
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryingClient 创建带重试的客户端
+func NewRetryingClient(next HuifuClient, policy RetryPolicy) *RetryingClient {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &RetryingClient{
+		next:   next,
+		policy: policy,
+		sleep:  sleepContext,
+	}
+}
+
+// Unwrap 返回被包装的客户端
+func (c *RetryingClient) Unwrap() HuifuClient {
+	return c.next
+}
+
+// CallAPI 调用汇付API，失败时按策略重试
+func (c *RetryingClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	spec, err := endpointRegistry.Lookup(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	params = withStableReqSeq(params)
+
+	var lastErr error
+	for attempt := 1; attempt <= c.policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			delay := c.policy.backoff(attempt - 1)
+			log.Printf("Retrying %s (attempt %d/%d, req_seq_id=%v) in %v after: %v",
+				endpoint, attempt, c.policy.MaxAttempts, params["req_seq_id"], delay, lastErr)
+			if err := c.sleep(ctx, delay); err != nil {
+				return nil, lastErr
+			}
+
+			// 非幂等写操作重试前先确认上一次是否已生效
+			if !spec.Idempotent {
+				applied, result, err := spec.StatusCheck(ctx, c.next, params)
+				if err != nil {
+					return nil, fmt.Errorf("%w (status check before retry failed: %v)", lastErr, err)
+				}
+				if applied {
+					log.Printf("%s already applied (req_seq_id=%v), skipping retry", endpoint, params["req_seq_id"])
+					return result, nil
+				}
+			}
+		}
+
+		result, err := c.next.CallAPI(ctx, endpoint, params)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+
+		if !IsRetryable(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		if !spec.Idempotent && spec.StatusCheck == nil {
+			// 无法确认写操作状态时不冒险重试
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// withStableReqSeq 复制参数并补齐 req_seq_id/req_date，保证各次尝试一致
+func withStableReqSeq(params map[string]interface{}) map[string]interface{} {
+	stable := make(map[string]interface{}, len(params)+2)
+	for k, v := range params {
+		stable[k] = v
+	}
+	if s, _ := stable["req_seq_id"].(string); s == "" {
+		stable["req_seq_id"] = generateReqSeqID()
+	}
+	if s, _ := stable["req_date"].(string); s == "" {
+		stable["req_date"] = time.Now().Format("20060102")
+	}
+	return stable
+}
+
+// sleepContext 等待指定时间，context结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// busiConfigStatus 通过配置查询确认微信配置是否已生效
+func busiConfigStatus(ctx context.Context, client HuifuClient, params map[string]interface{}) (bool, map[string]interface{}, error) {
+	busiReq, err := BusiConfigRequestFromParams(params)
+	if err != nil {
+		return false, nil, err
+	}
+
+	result, err := callHuifu(ctx, client, "/v2/merchant/busi/config/query", map[string]interface{}{
+		"huifu_id": busiReq.HuifuID,
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	var resp BusiConfigQueryResponse
+	if err := DecodeResponse(result, &resp); err != nil {
+		return false, nil, err
+	}
+	if resp.WxConfList == nil {
+		return false, nil, nil
+	}
+
+	for _, item := range resp.WxConfList.Items {
+		if !busiConfigApplied(busiReq, item) {
+			continue
+		}
+		return true, map[string]interface{}{
+			"resp_code": resp.RespCode,
+			"resp_desc": resp.RespDesc,
+			"data": map[string]interface{}{
+				"huifu_id":            busiReq.HuifuID,
+				"req_seq_id":          params["req_seq_id"],
+				"req_date":            params["req_date"],
+				"wx_woa_app_id":       item.WxWoaAppID,
+				"wx_woa_path":         item.WxWoaPath,
+				"wx_applet_app_id":    item.WxAppletAppID,
+				"wx_subscribe_app_id": item.WxSubscribeAppID,
+				"fee_type":            item.FeeType,
+				"config_status":       "APPLIED",
+			},
+		}, nil
+	}
+	return false, nil, nil
+}
+
+// busiConfigApplied 判断查询到的配置项是否包含本次写入的全部字段
+// 未写入的字段（空值）不比较；扩展字段须在配置项中原样出现，无法确认时视为未生效
+func busiConfigApplied(req *BusiConfigRequest, item WeChatConfigItem) bool {
+	if item.FeeType != req.FeeType {
+		return false
+	}
+	for _, field := range []struct{ written, current string }{
+		{req.WxWoaAppID, item.WxWoaAppID},
+		{req.WxWoaPath, item.WxWoaPath},
+		{req.WxAppletAppID, item.WxAppletAppID},
+		{req.WxSubscribeAppID, item.WxSubscribeAppID},
+	} {
+		if field.written != "" && field.written != field.current {
+			return false
+		}
+	}
+	for key, value := range req.ExtendInfos {
+		raw, exists := item.Extra[key]
+		if !exists {
+			return false
+		}
+		written, err := json.Marshal(value)
+		if err != nil || !reflect.DeepEqual(rawValue(written), rawValue(raw)) {
+			return false
+		}
+	}
+	return true
+}
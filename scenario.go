@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScenarioRule 场景中的一条规则：匹配接口与参数，返回指定的响应或故障
+type ScenarioRule struct {
+	Endpoint  string                 `json:"endpoint"`           // 接口路径，空或 "*" 匹配所有接口
+	Match     map[string]string      `json:"match,omitempty"`    // 参数匹配，值为 "*" 时只要求参数存在
+	DelayMs   int                    `json:"delay_ms,omitempty"` // 响应前等待的毫秒数
+	Timeout   bool                   `json:"timeout,omitempty"`  // 不响应，直到调用超时
+	RespCode  string                 `json:"resp_code,omitempty"`
+	RespDesc  string                 `json:"resp_desc,omitempty"`
+	Response  map[string]interface{} `json:"response,omitempty"`  // 原样返回的完整响应
+	Malformed string                 `json:"malformed,omitempty"` // 返回无法解析的报文内容
+}
+
+// Scenario 一组模拟规则，按顺序匹配，首条命中的规则生效
+type Scenario struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Rules       []ScenarioRule `json:"rules"`
+}
+
+// Validate 校验场景定义
+func (s *Scenario) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return &ValidationError{Field: "name", Reason: "is required"}
+	}
+	for i, rule := range s.Rules {
+		if rule.Endpoint != "" && rule.Endpoint != "*" {
+			if _, err := endpointRegistry.Lookup(rule.Endpoint); err != nil {
+				return fmt.Errorf("rule %d: %v", i, err)
+			}
+		}
+		if rule.DelayMs < 0 {
+			return &ValidationError{Field: fmt.Sprintf("rules[%d].delay_ms", i), Reason: "must not be negative"}
+		}
+		if rule.RespCode != "" && rule.Response != nil {
+			return &ValidationError{Field: fmt.Sprintf("rules[%d]", i), Reason: "resp_code and response are mutually exclusive"}
+		}
+	}
+	return nil
+}
+
+// matches 判断规则是否命中本次调用
+func (r *ScenarioRule) matches(endpoint string, params map[string]interface{}) bool {
+	if r.Endpoint != "" && r.Endpoint != "*" && r.Endpoint != endpoint {
+		return false
+	}
+	for name, want := range r.Match {
+		value, present := params[name]
+		if !present {
+			return false
+		}
+		if want != "*" && fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// apply 执行规则；规则只配置延迟时调用fallback生成正常响应
+func (r *ScenarioRule) apply(ctx context.Context, endpoint string, start time.Time, fallback func() map[string]interface{}) (map[string]interface{}, error) {
+	if r.DelayMs > 0 {
+		if err := sleepContext(ctx, time.Duration(r.DelayMs)*time.Millisecond); err != nil {
+			return nil, checkPhase(ctx, endpoint, PhaseTransport, start)
+		}
+	}
+
+	switch {
+	case r.Timeout:
+		<-ctx.Done()
+		return nil, checkPhase(ctx, endpoint, PhaseTransport, start)
+	case r.Malformed != "":
+		// 原样返回无法解析的报文，由调用方的响应解析失败
+		return map[string]interface{}{"data": r.Malformed}, nil
+	case r.Response != nil:
+		return copyMap(r.Response), nil
+	case r.RespCode != "":
+		return map[string]interface{}{
+			"resp_code": r.RespCode,
+			"resp_desc": r.RespDesc,
+			"data": map[string]interface{}{
+				"resp_code": r.RespCode,
+				"resp_desc": r.RespDesc,
+			},
+		}, nil
+	}
+	return fallback(), nil
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = copyMap(nested)
+		}
+		copied[k] = v
+	}
+	return copied
+}
+
+// ScenarioManager 管理场景定义及各sys_id当前启用的场景
+type ScenarioManager struct {
+	mu        sync.RWMutex
+	dir       string
+	scenarios map[string]*Scenario
+	active    map[string]string // sys_id -> 场景名
+}
+
+// NewScenarioManager 创建场景管理器，dir为场景文件目录
+func NewScenarioManager(dir string) *ScenarioManager {
+	return &ScenarioManager{
+		dir:       dir,
+		scenarios: make(map[string]*Scenario),
+		active:    make(map[string]string),
+	}
+}
+
+// Load 从目录加载全部 *.json 场景文件，替换已加载的场景；已启用但不再存在的场景被停用
+func (m *ScenarioManager) Load() error {
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list scenario files: %v", err)
+	}
+
+	scenarios := make(map[string]*Scenario, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read scenario %s: %v", file, err)
+		}
+		var scenario Scenario
+		if err := json.Unmarshal(data, &scenario); err != nil {
+			return fmt.Errorf("failed to parse scenario %s: %v", file, err)
+		}
+		if scenario.Name == "" {
+			scenario.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+		}
+		if err := scenario.Validate(); err != nil {
+			return fmt.Errorf("invalid scenario %s: %w", file, err)
+		}
+		scenarios[scenario.Name] = &scenario
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scenarios = scenarios
+	for sysID, name := range m.active {
+		if _, exists := scenarios[name]; !exists {
+			log.Printf("Scenario %s no longer exists, deactivated for sys_id=%s", name, sysID)
+			delete(m.active, sysID)
+		}
+	}
+	return nil
+}
+
+// Activate 为sys_id启用场景
+func (m *ScenarioManager) Activate(sysID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.scenarios[name]; !exists {
+		return fmt.Errorf("scenario not found: %s", name)
+	}
+	m.active[sysID] = name
+	return nil
+}
+
+// Deactivate 停用sys_id的场景，恢复默认模拟响应
+func (m *ScenarioManager) Deactivate(sysID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.active, sysID)
+}
+
+// Match 返回sys_id当前场景中首条命中的规则
+func (m *ScenarioManager) Match(sysID, endpoint string, params map[string]interface{}) (string, *ScenarioRule) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name, ok := m.active[sysID]
+	if !ok {
+		return "", nil
+	}
+	scenario := m.scenarios[name]
+	for i := range scenario.Rules {
+		if scenario.Rules[i].matches(endpoint, params) {
+			rule := scenario.Rules[i]
+			return name, &rule
+		}
+	}
+	return name, nil
+}
+
+// List 返回已加载的场景（按名称排序）及各sys_id启用的场景
+func (m *ScenarioManager) List() ([]*Scenario, map[string]string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scenarios := make([]*Scenario, 0, len(m.scenarios))
+	for _, scenario := range m.scenarios {
+		scenarios = append(scenarios, scenario)
+	}
+	sort.Slice(scenarios, func(i, j int) bool { return scenarios[i].Name < scenarios[j].Name })
+
+	active := make(map[string]string, len(m.active))
+	for sysID, name := range m.active {
+		active[sysID] = name
+	}
+	return scenarios, active
+}
+
+// scenarioDir 场景文件目录，由 HUIFU_SCENARIO_DIR 指定（默认 ./scenarios）
+func scenarioDir() string {
+	if dir := os.Getenv("HUIFU_SCENARIO_DIR"); dir != "" {
+		return dir
+	}
+	return "./scenarios"
+}
+
+// 场景校验依赖接口注册表，在 main 中完成加载
+var scenarioManager = NewScenarioManager(scenarioDir())
+
+// listScenarios 列出模拟场景及各sys_id启用情况
+func listScenarios(c *gin.Context) {
+	scenarios, active := scenarioManager.List()
+	c.JSON(http.StatusOK, gin.H{
+		"scenarios": scenarios,
+		"active":    active,
+	})
+}
+
+// reloadScenarios 重新加载场景文件
+func reloadScenarios(c *gin.Context) {
+	if err := scenarioManager.Load(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to reload scenarios",
+			"details": err.Error(),
+		})
+		return
+	}
+	scenarios, _ := scenarioManager.List()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scenarios reloaded",
+		"count":   len(scenarios),
+	})
+}
+
+// activateScenario 为sys_id启用场景
+func activateScenario(c *gin.Context) {
+	sysID := c.Param("sys_id")
+	var req struct {
+		Scenario string `json:"scenario" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := scenarioManager.Activate(sysID, req.Scenario); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Scenario not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("Scenario %s activated for sys_id=%s", req.Scenario, sysID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Scenario activated",
+		"sys_id":   sysID,
+		"scenario": req.Scenario,
+	})
+}
+
+// deactivateScenario 停用sys_id的场景
+func deactivateScenario(c *gin.Context) {
+	sysID := c.Param("sys_id")
+	scenarioManager.Deactivate(sysID)
+
+	log.Printf("Scenario deactivated for sys_id=%s", sysID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scenario deactivated",
+		"sys_id":  sysID,
+	})
+}
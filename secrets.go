@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 私钥来源
+const (
+	KeySourceInline = "inline" // 配置请求中直接提交的私钥
+	KeySourceEnv    = "env"    // env:NAME
+	KeySourceFile   = "file"   // file:///path/to/key.pem
+	KeySourceVault  = "vault"  // vault://<mount>/<path>#<field>，Vault KV v2
+)
+
+// defaultVaultField Vault URI 未指定字段时读取的字段名
+const defaultVaultField = "private_key"
+
+// secretRefreshInterval 检查缓存的Vault密钥是否需要重新读取、令牌是否需要续期的间隔
+const secretRefreshInterval = 30 * time.Second
+
+// SecretURI 解析后的私钥引用
+type SecretURI struct {
+	Kind  string
+	Name  string // env：环境变量名
+	Path  string // file：文件路径；vault：secret路径
+	Mount string // vault：KV引擎挂载点
+	Field string // vault：secret中的字段
+	raw   string
+}
+
+func (u *SecretURI) String() string {
+	return u.raw
+}
+
+// SecretPolicy 私钥引用白名单，未列出的文件目录、环境变量及Vault挂载点一律拒绝
+type SecretPolicy struct {
+	dirs        []string
+	envPrefixes []string
+	vaultMounts map[string]bool
+}
+
+// ParseSecretPolicy 解析白名单：dirs 为以路径分隔符（Linux为冒号）分隔的目录，envPrefixes、vaultMounts 以逗号分隔
+func ParseSecretPolicy(dirs, envPrefixes, vaultMounts string) *SecretPolicy {
+	policy := &SecretPolicy{vaultMounts: make(map[string]bool)}
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir = strings.TrimSpace(dir); dir == "" || !filepath.IsAbs(dir) {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		policy.dirs = append(policy.dirs, dir)
+		// 目录本身是符号链接时，同时允许其实际路径，Resolve按实际路径检查
+		if real, err := filepath.EvalSymlinks(dir); err == nil && real != dir {
+			policy.dirs = append(policy.dirs, real)
+		}
+	}
+	for _, prefix := range strings.Split(envPrefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			policy.envPrefixes = append(policy.envPrefixes, prefix)
+		}
+	}
+	for _, mount := range strings.Split(vaultMounts, ",") {
+		if mount = strings.Trim(strings.TrimSpace(mount), "/"); mount != "" {
+			policy.vaultMounts[mount] = true
+		}
+	}
+	return policy
+}
+
+// LoadSecretPolicy 从环境变量 HUIFU_SECRET_ALLOW_DIRS、HUIFU_SECRET_ALLOW_ENV_PREFIXES、
+// HUIFU_SECRET_ALLOW_VAULT_MOUNTS 加载白名单，未配置时全部拒绝
+func LoadSecretPolicy() *SecretPolicy {
+	return ParseSecretPolicy(os.Getenv("HUIFU_SECRET_ALLOW_DIRS"), os.Getenv("HUIFU_SECRET_ALLOW_ENV_PREFIXES"), os.Getenv("HUIFU_SECRET_ALLOW_VAULT_MOUNTS"))
+}
+
+// AllowsFile 判断文件是否位于允许的目录（含子目录）内
+func (p *SecretPolicy) AllowsFile(path string) bool {
+	for _, dir := range p.dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsEnv 判断环境变量名是否带有允许的前缀；Vault令牌所在的变量始终拒绝
+func (p *SecretPolicy) AllowsEnv(name string) bool {
+	if name == "VAULT_TOKEN" {
+		return false
+	}
+	for _, prefix := range p.envPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsVaultMount 判断Vault挂载点是否在白名单内
+func (p *SecretPolicy) AllowsVaultMount(mount string) bool {
+	return p.vaultMounts[mount]
+}
+
+var secretPolicy = LoadSecretPolicy()
+
+// ParseSecretURI 解析私钥URI，并按 secretPolicy 拒绝白名单以外的来源
+func ParseSecretURI(raw string) (*SecretURI, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: err.Error()}
+	}
+
+	u := &SecretURI{Kind: parsed.Scheme, raw: raw}
+	switch parsed.Scheme {
+	case KeySourceEnv:
+		u.Name = firstNonEmpty(parsed.Opaque, parsed.Host)
+		if u.Name == "" {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: "env URI must name a variable, like env:HUIFU_KEY"}
+		}
+		if !secretPolicy.AllowsEnv(u.Name) {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: fmt.Sprintf("environment variable %s does not match HUIFU_SECRET_ALLOW_ENV_PREFIXES", u.Name)}
+		}
+	case KeySourceFile:
+		u.Path = firstNonEmpty(parsed.Path, parsed.Opaque)
+		if u.Path == "" || !filepath.IsAbs(u.Path) {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: "file URI must include an absolute path, like file:///etc/huifu/key.pem"}
+		}
+		u.Path = filepath.Clean(u.Path)
+		if !secretPolicy.AllowsFile(u.Path) {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: fmt.Sprintf("%s is outside the directories allowed by HUIFU_SECRET_ALLOW_DIRS", u.Path)}
+		}
+	case KeySourceVault:
+		u.Mount = parsed.Host
+		u.Path = strings.Trim(parsed.Path, "/")
+		u.Field = firstNonEmpty(parsed.Fragment, defaultVaultField)
+		if u.Mount == "" || u.Path == "" {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: "vault URI must be vault://<mount>/<path>#<field>"}
+		}
+		if !secretPolicy.AllowsVaultMount(u.Mount) {
+			return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: fmt.Sprintf("vault mount %s is not listed in HUIFU_SECRET_ALLOW_VAULT_MOUNTS", u.Mount)}
+		}
+	default:
+		return nil, &ValidationError{Field: "rsa_private_key_uri", Reason: fmt.Sprintf("unsupported scheme %q, expected env, file or vault", parsed.Scheme)}
+	}
+	return u, nil
+}
+
+// cachedSecret 缓存的Vault密钥
+type cachedSecret struct {
+	uri       *SecretURI
+	value     string
+	expiresAt time.Time
+	createdAt time.Time // secret当前版本的创建时间
+}
+
+// SecretResolver 按URI读取私钥；Vault密钥缓存 cacheTTL 后重新读取，并在令牌到期前续期
+type SecretResolver struct {
+	mu         sync.Mutex
+	cache      map[string]*cachedSecret
+	vaultAddr  string
+	vaultToken string
+	cacheTTL   time.Duration
+	httpClient *http.Client
+	onChange   []func(uri string)
+
+	tokenTTL       time.Duration // 令牌上次获得的有效期，0表示永不过期
+	tokenExpiresAt time.Time
+	tokenRenewable bool
+}
+
+// NewSecretResolver 创建私钥解析器；KV v2读取不返回租约，Vault密钥缓存 cacheTTL 后重新读取
+func NewSecretResolver(vaultAddr, vaultToken string, cacheTTL time.Duration) *SecretResolver {
+	return &SecretResolver{
+		cache:      make(map[string]*cachedSecret),
+		vaultAddr:  strings.TrimSuffix(vaultAddr, "/"),
+		vaultToken: vaultToken,
+		cacheTTL:   cacheTTL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// OnChange 注册回调，Vault中的密钥内容变化时调用
+func (r *SecretResolver) OnChange(fn func(uri string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = append(r.onChange, fn)
+}
+
+// Resolve 读取URI引用的私钥
+func (r *SecretResolver) Resolve(ctx context.Context, raw string) (string, error) {
+	uri, err := ParseSecretURI(raw)
+	if err != nil {
+		return "", err
+	}
+
+	switch uri.Kind {
+	case KeySourceEnv:
+		value := os.Getenv(uri.Name)
+		if strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("environment variable %s is not set", uri.Name)
+		}
+		return value, nil
+	case KeySourceFile:
+		// 按实际路径再检查一次，避免白名单目录内的符号链接指向目录外
+		path, err := filepath.EvalSymlinks(uri.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key file: %v", err)
+		}
+		if !secretPolicy.AllowsFile(path) {
+			return "", &ValidationError{Field: "rsa_private_key_uri", Reason: fmt.Sprintf("%s resolves to %s, outside the directories allowed by HUIFU_SECRET_ALLOW_DIRS", uri.Path, path)}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read key file: %v", err)
+		}
+		return string(data), nil
+	}
+
+	r.mu.Lock()
+	entry, cached := r.cache[raw]
+	if cached && time.Now().Before(entry.expiresAt) {
+		value := entry.value
+		r.mu.Unlock()
+		return value, nil
+	}
+	r.mu.Unlock()
+
+	entry, err = r.readVault(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.cache[raw] = entry
+	r.mu.Unlock()
+	return entry.value, nil
+}
+
+// CreatedAt 返回URI引用私钥的创建时间：Vault取当前版本的创建时间（须已读取过），文件取修改时间，环境变量无法获知
+func (r *SecretResolver) CreatedAt(raw string) (time.Time, bool) {
+	uri, err := ParseSecretURI(raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch uri.Kind {
+	case KeySourceFile:
+		info, err := os.Stat(uri.Path)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return info.ModTime(), true
+	case KeySourceVault:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if entry, cached := r.cache[raw]; cached && !entry.createdAt.IsZero() {
+			return entry.createdAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Forget 清除URI的缓存
+func (r *SecretResolver) Forget(raw string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.cache, raw)
+}
+
+// Start 定时续期Vault令牌并重新读取即将到期的Vault密钥，ctx结束时停止
+func (r *SecretResolver) Start(ctx context.Context) {
+	go func() {
+		if r.vaultAddr != "" && r.vaultToken != "" {
+			if err := r.lookupToken(ctx); err != nil {
+				log.Printf("Failed to look up Vault token: %v", err)
+			}
+		}
+
+		ticker := time.NewTicker(secretRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.renewTokenIfDue(ctx)
+				r.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// lookupToken 查询令牌的剩余有效期及是否可续期：GET <addr>/v1/auth/token/lookup-self
+func (r *SecretResolver) lookupToken(ctx context.Context) error {
+	var resp struct {
+		Data struct {
+			TTL       int  `json:"ttl"`
+			Renewable bool `json:"renewable"`
+		} `json:"data"`
+	}
+	if err := r.vaultRequest(ctx, http.MethodGet, "/v1/auth/token/lookup-self", nil, &resp); err != nil {
+		return err
+	}
+
+	r.setTokenTTL(resp.Data.TTL, resp.Data.Renewable)
+	return nil
+}
+
+// renewTokenIfDue 令牌剩余有效期不足一半或不足两个检查间隔时续期：POST <addr>/v1/auth/token/renew-self
+// 永不过期或不可续期的令牌不处理；续期失败时记录日志，下次检查重试
+func (r *SecretResolver) renewTokenIfDue(ctx context.Context) {
+	r.mu.Lock()
+	remaining := time.Until(r.tokenExpiresAt)
+	due := r.tokenRenewable && r.tokenTTL > 0 && (remaining < r.tokenTTL/2 || remaining < 2*secretRefreshInterval)
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+
+	var resp struct {
+		Auth struct {
+			LeaseDuration int  `json:"lease_duration"`
+			Renewable     bool `json:"renewable"`
+		} `json:"auth"`
+	}
+	if err := r.vaultRequest(ctx, http.MethodPost, "/v1/auth/token/renew-self", nil, &resp); err != nil {
+		log.Printf("Failed to renew Vault token (expires in %s): %v", remaining.Round(time.Second), err)
+		return
+	}
+	r.setTokenTTL(resp.Auth.LeaseDuration, resp.Auth.Renewable)
+}
+
+func (r *SecretResolver) setTokenTTL(seconds int, renewable bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokenTTL = time.Duration(seconds) * time.Second
+	r.tokenExpiresAt = time.Now().Add(r.tokenTTL)
+	r.tokenRenewable = renewable
+}
+
+// refresh 重新读取在下一次检查前到期的缓存，内容变化时通知
+func (r *SecretResolver) refresh(ctx context.Context) {
+	r.mu.Lock()
+	var due []*cachedSecret
+	for _, entry := range r.cache {
+		if time.Until(entry.expiresAt) < 2*secretRefreshInterval {
+			due = append(due, entry)
+		}
+	}
+	callbacks := append([]func(string){}, r.onChange...)
+	r.mu.Unlock()
+
+	for _, entry := range due {
+		fresh, err := r.readVault(ctx, entry.uri)
+		if err != nil {
+			log.Printf("Failed to refresh secret %s: %v", entry.uri, err)
+			continue
+		}
+		r.mu.Lock()
+		r.cache[entry.uri.raw] = fresh
+		r.mu.Unlock()
+
+		if fresh.value != entry.value {
+			log.Printf("Secret %s changed", entry.uri)
+			for _, fn := range callbacks {
+				fn(entry.uri.raw)
+			}
+		}
+	}
+}
+
+// readVault 读取KV v2 secret：GET <addr>/v1/<mount>/data/<path>
+func (r *SecretResolver) readVault(ctx context.Context, uri *SecretURI) (*cachedSecret, error) {
+	var resp struct {
+		Data struct {
+			Data     map[string]interface{} `json:"data"`
+			Metadata struct {
+				CreatedTime time.Time `json:"created_time"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	if err := r.vaultRequest(ctx, http.MethodGet, fmt.Sprintf("/v1/%s/data/%s", uri.Mount, uri.Path), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", uri, err)
+	}
+
+	value, ok := resp.Data.Data[uri.Field].(string)
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("secret %s has no string field %q", uri, uri.Field)
+	}
+	return &cachedSecret{
+		uri:       uri,
+		value:     value,
+		expiresAt: time.Now().Add(r.cacheTTL),
+		createdAt: resp.Data.Metadata.CreatedTime,
+	}, nil
+}
+
+// vaultRequest 调用Vault HTTP API，错误时返回响应中的errors
+func (r *SecretResolver) vaultRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	if r.vaultAddr == "" {
+		return fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.vaultAddr+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", r.vaultToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(data, &vaultErr)
+		if len(vaultErr.Errors) == 0 {
+			return fmt.Errorf("vault returned HTTP %d", resp.StatusCode)
+		}
+		return fmt.Errorf("vault returned HTTP %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+	}
+	return json.Unmarshal(data, out)
+}
+
+// keySource 返回配置的私钥来源及URI
+func keySource(config *ConfigRequest) (string, string) {
+	if config.RSAPrivateKeyURI == "" {
+		return KeySourceInline, ""
+	}
+	if uri, err := ParseSecretURI(config.RSAPrivateKeyURI); err == nil {
+		return uri.Kind, config.RSAPrivateKeyURI
+	}
+	return "unknown", config.RSAPrivateKeyURI
+}
+
+// secretCacheTTL Vault密钥的缓存时间，由 HUIFU_SECRET_CACHE_SECONDS 指定（默认300）
+func secretCacheTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("HUIFU_SECRET_CACHE_SECONDS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+var secretResolver = NewSecretResolver(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), secretCacheTTL())
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"huifu-config-system/vaultsim"
+)
+
+// useSecretPolicy 在测试期间替换私钥引用白名单
+func useSecretPolicy(t *testing.T, policy *SecretPolicy) {
+	t.Helper()
+
+	saved := secretPolicy
+	secretPolicy = policy
+	t.Cleanup(func() { secretPolicy = saved })
+}
+
+func TestSecretPolicy(t *testing.T) {
+	dir := t.TempDir()
+	useSecretPolicy(t, ParseSecretPolicy(filepath.Join(dir, "keys"), "HUIFU_KEY_,VAULT_", "secret"))
+
+	tests := []struct {
+		uri     string
+		allowed bool
+	}{
+		{"file://" + filepath.Join(dir, "keys", "6666.pem"), true},
+		{"file://" + filepath.Join(dir, "keys", "..", "other.pem"), false},
+		{"file://" + filepath.Join(dir, "keys"), false},
+		{"file:///etc/passwd", false},
+		{"file:relative.pem", false},
+		{"env:HUIFU_KEY_6666", true},
+		{"env:HOME", false},
+		{"env:VAULT_TOKEN", false},
+		{"vault://secret/huifu/6666#private_key", true},
+		{"vault://sys/huifu/6666", false},
+	}
+	for _, tt := range tests {
+		if _, err := ParseSecretURI(tt.uri); (err == nil) != tt.allowed {
+			t.Errorf("ParseSecretURI(%q) err = %v, want allowed: %v", tt.uri, err, tt.allowed)
+		}
+	}
+
+	useSecretPolicy(t, ParseSecretPolicy("", "", ""))
+	for _, uri := range []string{"file://" + filepath.Join(dir, "keys", "6666.pem"), "env:HUIFU_KEY_6666", "vault://secret/huifu/6666"} {
+		if _, err := ParseSecretURI(uri); err == nil {
+			t.Errorf("ParseSecretURI(%q) accepted without an allowlist", uri)
+		}
+	}
+}
+
+func TestResolveRejectsSymlinkOutsideAllowedDirs(t *testing.T) {
+	dir := t.TempDir()
+	keys := filepath.Join(dir, "keys")
+	if err := os.Mkdir(keys, 0700); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "outside.pem")
+	if err := os.WriteFile(outside, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(keys, "link.pem")); err != nil {
+		t.Fatal(err)
+	}
+	useSecretPolicy(t, ParseSecretPolicy(keys, "", ""))
+
+	resolver := NewSecretResolver("", "", 0)
+	if _, err := resolver.Resolve(context.Background(), "file://"+filepath.Join(keys, "link.pem")); err == nil {
+		t.Fatal("Resolve followed a symlink out of the allowed directory")
+	}
+}
+
+func TestVaultTokenRenewal(t *testing.T) {
+	useSecretPolicy(t, ParseSecretPolicy("", "", "secret"))
+	vault := vaultsim.New("root", 40*time.Second)
+	vault.Put("secret", "huifu/6666", map[string]interface{}{"private_key": "key v1"})
+	ts := httptest.NewServer(vault)
+	defer ts.Close()
+
+	ctx := context.Background()
+	resolver := NewSecretResolver(ts.URL, "root", time.Minute)
+	if value, err := resolver.Resolve(ctx, "vault://secret/huifu/6666"); err != nil || value != "key v1" {
+		t.Fatalf("Resolve = %q, %v", value, err)
+	}
+	if err := resolver.lookupToken(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 剩余40秒不足两个检查间隔，应续期
+	before := vault.TokenExpiresAt()
+	time.Sleep(10 * time.Millisecond)
+	resolver.renewTokenIfDue(ctx)
+	if !vault.TokenExpiresAt().After(before) {
+		t.Fatal("token was not renewed")
+	}
+
+	// 令牌到期后读取失败
+	vault.ExpireToken()
+	resolver.Forget("vault://secret/huifu/6666")
+	if _, err := resolver.Resolve(ctx, "vault://secret/huifu/6666"); err == nil {
+		t.Fatal("Resolve succeeded with an expired token")
+	}
+}
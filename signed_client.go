@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"huifu-config-system/huifusign"
+)
+
+// 汇付v2接口地址，可由 HUIFU_BASE_URL 改写到本地模拟服务
+const (
+	huifuProductionURL = "https://api.huifu.com"
+	huifuTestURL       = "https://spin-test.cloudpnr.com"
+)
+
+// maxResponseSize 汇付响应的最大字节数
+const maxResponseSize = 10 << 20
+
+// SignerHuifuClient 按汇付v2 HTTP协议直接调用接口，签名与验签交给 Signer
+// 私钥由签名器持有，不生成SDK配置文件
+type SignerHuifuClient struct {
+	sysID      string
+	productID  string
+	baseURL    string
+	signer     Signer
+	imported   bool // 私钥由本客户端登记，删除配置时从签名器移除
+	httpClient *http.Client
+}
+
+// NewSignerHuifuClient 将配置中的私钥登记到签名器并创建客户端
+// 配置未给出私钥时使用签名守护进程中已有的私钥（-keys 预置），不导入
+func NewSignerHuifuClient(config *ConfigRequest, isProduction bool, signer Signer) (*SignerHuifuClient, error) {
+	imported := config.RSAPrivateKey != ""
+	if imported {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := signer.ImportKey(ctx, config.SysID, config.RSAPrivateKey); err != nil {
+			return nil, fmt.Errorf("failed to import signing key: %v", err)
+		}
+	}
+
+	baseURL := huifuTestURL
+	if isProduction {
+		baseURL = huifuProductionURL
+	}
+	return &SignerHuifuClient{
+		sysID:      config.SysID,
+		productID:  config.ProductID,
+		baseURL:    baseURL,
+		signer:     signer,
+		imported:   imported,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// CallAPI 签名、发送请求并校验响应签名
+func (c *SignerHuifuClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	spec, err := endpointRegistry.Lookup(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.ValidateParams(params); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withEndpointDeadline(ctx, endpoint)
+	defer cancel()
+	start := time.Now()
+
+	// 签名：data按汇付规范化后的原文签名，并以同一原文发送
+	content, err := huifusign.Canonicalize(params)
+	if err != nil {
+		return nil, err
+	}
+	sign, err := runPhase(ctx, endpoint, PhaseSigning, start, func() (string, error) {
+		return c.signer.Sign(ctx, c.sysID, content)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"sys_id":     c.sysID,
+		"product_id": c.productID,
+		"data":       json.RawMessage(content),
+		"sign":       sign,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	respBody, err := runPhase(ctx, endpoint, PhaseTransport, start, func() ([]byte, error) {
+		return c.post(ctx, endpoint, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPhase(ctx, endpoint, PhaseDecoding, start); err != nil {
+		return nil, err
+	}
+	return c.decode(ctx, endpoint, respBody)
+}
+
+// post 发送请求，返回响应体
+func (c *SignerHuifuClient) post(ctx context.Context, endpoint string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	// 5xx，以及网关返回的非JSON 4xx页面（如HTML错误页），均按上游错误处理
+	if resp.StatusCode >= http.StatusInternalServerError ||
+		(resp.StatusCode >= http.StatusBadRequest && !json.Valid(data)) {
+		return nil, &HuifuError{
+			Category: CategoryNetwork,
+			Endpoint: endpoint,
+			Err:      fmt.Errorf("huifu returned HTTP %d", resp.StatusCode),
+		}
+	}
+	return data, nil
+}
+
+// decode 解析响应并用签名器校验汇付签名
+func (c *SignerHuifuClient) decode(ctx context.Context, endpoint string, body []byte) (map[string]interface{}, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+		Sign string          `json:"sign"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || len(envelope.Data) == 0 {
+		return nil, &ResponseDecodeError{Err: fmt.Errorf("response from %s is not a signed Huifu response", endpoint)}
+	}
+
+	content, data, err := huifusign.ParseData(envelope.Data)
+	if err != nil {
+		return nil, &ResponseDecodeError{Err: fmt.Errorf("response from %s: %v", endpoint, err)}
+	}
+	if err := c.signer.Verify(ctx, content, envelope.Sign); err != nil {
+		return nil, &HuifuError{
+			Category: CategorySignature,
+			Endpoint: endpoint,
+			Err:      fmt.Errorf("response signature verification failed: %w", err),
+		}
+	}
+
+	return map[string]interface{}{
+		"data": data,
+		"sign": envelope.Sign,
+	}, nil
+}
+
+// Cleanup 从签名器删除本客户端登记的私钥，守护进程预置的私钥保留
+func (c *SignerHuifuClient) Cleanup() {
+	if !c.imported {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.signer.RemoveKey(ctx, c.sysID); err != nil {
+		log.Printf("Failed to remove signing key for sys_id=%s: %v", c.sysID, err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"huifu-config-system/huifusign"
+)
+
+// TestSignerClientUndecodableResponses 汇付或网关返回无法解析的响应时按上游错误（502）处理，而非内部错误
+func TestSignerClientUndecodableResponses(t *testing.T) {
+	huifuKey, err := huifusign.ParsePublicKey(huifuPublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	client, err := NewSignerHuifuClient(&ConfigRequest{SysID: contractSysID, ProductID: "PAYUN", RSAPrivateKey: privateKey}, false, NewLocalSigner(huifuKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"HTML page", http.StatusOK, "<html><body>maintenance</body></html>"},
+		{"HTML 4xx from a gateway", http.StatusForbidden, "<html><body>403 Forbidden</body></html>"},
+		{"HTML 5xx from a gateway", http.StatusBadGateway, "<html><body>502 Bad Gateway</body></html>"},
+		{"JSON without data", http.StatusOK, `{"message":"ok"}`},
+		{"data is not an object", http.StatusOK, `{"data":"maintenance","sign":""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			client.baseURL = ts.URL
+
+			_, err := client.CallAPI(context.Background(), "/v2/merchant/basicdata/query", map[string]interface{}{"huifu_id": contractSysID})
+			if err == nil {
+				t.Fatal("CallAPI succeeded")
+			}
+			if huifuErr := AsHuifuError("", err); huifuErr.Category != CategoryNetwork || huifuErr.HTTPStatus() != http.StatusBadGateway {
+				t.Errorf("err = %v, category %s (HTTP %d), want network (HTTP 502)", err, huifuErr.Category, huifuErr.HTTPStatus())
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"huifu-config-system/huifusign"
+	"huifu-config-system/signerd"
+)
+
+// Signer 持有商户私钥的签名器，客户端只提交签名原文，不接触私钥
+type Signer interface {
+	// ImportKey 登记sys_id的私钥
+	ImportKey(ctx context.Context, sysID, privateKey string) error
+	// RemoveKey 删除sys_id的私钥
+	RemoveKey(ctx context.Context, sysID string) error
+	// PublicKey 返回sys_id私钥对应的公钥（不带标记的base64 PKIX DER）
+	PublicKey(ctx context.Context, sysID string) (string, error)
+	// Sign 用sys_id的私钥对原文签名，返回base64签名
+	Sign(ctx context.Context, sysID string, content []byte) (string, error)
+	// Verify 用汇付公钥校验响应签名
+	Verify(ctx context.Context, content []byte, sign string) error
+	// HoldsKeys 私钥是否保存在本进程之外
+	HoldsKeys() bool
+}
+
+// LocalSigner 在本进程内存中保存私钥的签名器
+type LocalSigner struct {
+	mu       sync.RWMutex
+	keys     map[string]*rsa.PrivateKey
+	huifuKey *rsa.PublicKey
+}
+
+// NewLocalSigner 创建进程内签名器
+func NewLocalSigner(huifuKey *rsa.PublicKey) *LocalSigner {
+	return &LocalSigner{
+		keys:     make(map[string]*rsa.PrivateKey),
+		huifuKey: huifuKey,
+	}
+}
+
+func (s *LocalSigner) ImportKey(ctx context.Context, sysID, privateKey string) error {
+	key, err := huifusign.ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[sysID] = key
+	return nil
+}
+
+func (s *LocalSigner) RemoveKey(ctx context.Context, sysID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, sysID)
+	return nil
+}
+
+func (s *LocalSigner) PublicKey(ctx context.Context, sysID string) (string, error) {
+	key, err := s.key(sysID)
+	if err != nil {
+		return "", err
+	}
+	return huifusign.EncodePublicKey(&key.PublicKey)
+}
+
+func (s *LocalSigner) Sign(ctx context.Context, sysID string, content []byte) (string, error) {
+	key, err := s.key(sysID)
+	if err != nil {
+		return "", err
+	}
+	return huifusign.Sign(key, content)
+}
+
+func (s *LocalSigner) key(sysID string) (*rsa.PrivateKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, exists := s.keys[sysID]
+	if !exists {
+		return nil, fmt.Errorf("no signing key for sys_id %s", sysID)
+	}
+	return key, nil
+}
+
+func (s *LocalSigner) Verify(ctx context.Context, content []byte, sign string) error {
+	return huifusign.Verify(s.huifuKey, content, sign)
+}
+
+func (s *LocalSigner) HoldsKeys() bool {
+	return false
+}
+
+// SocketSigner 通过Unix socket使用签名守护进程（cmd/huifu-signer），私钥不进入本进程
+type SocketSigner struct {
+	client *signerd.Client
+}
+
+// NewSocketSigner 创建连接守护进程的签名器
+func NewSocketSigner(path string) *SocketSigner {
+	return &SocketSigner{client: signerd.NewClient(path)}
+}
+
+func (s *SocketSigner) ImportKey(ctx context.Context, sysID, privateKey string) error {
+	return s.client.Import(ctx, sysID, privateKey)
+}
+
+func (s *SocketSigner) RemoveKey(ctx context.Context, sysID string) error {
+	return s.client.Remove(ctx, sysID)
+}
+
+func (s *SocketSigner) PublicKey(ctx context.Context, sysID string) (string, error) {
+	return s.client.PublicKey(ctx, sysID)
+}
+
+func (s *SocketSigner) Sign(ctx context.Context, sysID string, content []byte) (string, error) {
+	return s.client.Sign(ctx, sysID, content)
+}
+
+func (s *SocketSigner) Verify(ctx context.Context, content []byte, sign string) error {
+	return s.client.Verify(ctx, content, sign)
+}
+
+func (s *SocketSigner) HoldsKeys() bool {
+	return true
+}
+
+// loadSigner 按环境变量选择签名器
+//
+//	HUIFU_SIGNER_SOCKET  使用该路径上的签名守护进程
+//	HUIFU_SIGNER=local   进程内签名，不经过SDK
+//
+// 均未设置时返回nil，沿用SDK客户端
+func loadSigner() Signer {
+	if path := os.Getenv("HUIFU_SIGNER_SOCKET"); path != "" {
+		log.Printf("Signing Huifu requests through signer daemon at %s", path)
+		return NewSocketSigner(path)
+	}
+	if os.Getenv("HUIFU_SIGNER") == "local" {
+		huifuKey, err := huifusign.ParsePublicKey(huifuPublicKey())
+		if err != nil {
+			log.Fatal("Failed to parse Huifu public key:", err)
+		}
+		return NewLocalSigner(huifuKey)
+	}
+	return nil
+}
+
+var keySigner = loadSigner()
+
+// signerHoldsKeys 是否使用私钥不在本进程的签名守护进程
+func signerHoldsKeys() bool {
+	return keySigner != nil && keySigner.HoldsKeys()
+}
@@ -0,0 +1,95 @@
+package signerd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// Client 签名守护进程客户端，每个请求使用独立连接
+type Client struct {
+	path string
+}
+
+// NewClient 创建连接path上守护进程的客户端
+func NewClient(path string) *Client {
+	return &Client{path: path}
+}
+
+// Sign 用sys_id的私钥对原文签名
+func (c *Client) Sign(ctx context.Context, sysID string, content []byte) (string, error) {
+	resp, err := c.do(ctx, &Request{Op: OpSign, SysID: sysID, Content: content})
+	if err != nil {
+		return "", err
+	}
+	return resp.Sign, nil
+}
+
+// Verify 用汇付公钥校验签名
+func (c *Client) Verify(ctx context.Context, content []byte, sign string) error {
+	_, err := c.do(ctx, &Request{Op: OpVerify, Content: content, Sign: sign})
+	return err
+}
+
+// PublicKey 查询sys_id私钥对应的公钥（不带标记的base64 PKIX DER）
+func (c *Client) PublicKey(ctx context.Context, sysID string) (string, error) {
+	resp, err := c.do(ctx, &Request{Op: OpPublicKey, SysID: sysID})
+	if err != nil {
+		return "", err
+	}
+	return resp.PublicKey, nil
+}
+
+// Import 将sys_id的私钥导入守护进程
+func (c *Client) Import(ctx context.Context, sysID, privateKey string) error {
+	_, err := c.do(ctx, &Request{Op: OpImport, SysID: sysID, PrivateKey: privateKey})
+	return err
+}
+
+// Remove 删除守护进程中sys_id的私钥
+func (c *Client) Remove(ctx context.Context, sysID string) error {
+	_, err := c.do(ctx, &Request{Op: OpRemove, SysID: sysID})
+	return err
+}
+
+// do 发送请求并读取响应，遵守ctx的截止时间
+func (c *Client) do(ctx context.Context, req *Request) (*Response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signer: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send signer request: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to read signer response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid signer response: %v", err)
+	}
+	if resp.Code != "" {
+		return nil, &Error{Code: resp.Code, Message: resp.Error}
+	}
+	return &resp, nil
+}
@@ -0,0 +1,29 @@
+//go:build linux
+
+package signerd
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerCredentials 通过 SO_PEERCRED 读取对端进程的凭据，由内核提供，对端无法伪造
+func peerCredentials(conn *net.UnixConn) (Peer, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return Peer{}, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return Peer{}, err
+	}
+	if credErr != nil {
+		return Peer{}, fmt.Errorf("failed to read peer credentials: %v", credErr)
+	}
+	return Peer{UID: cred.Uid, GID: cred.Gid, PID: cred.Pid}, nil
+}
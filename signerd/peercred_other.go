@@ -0,0 +1,14 @@
+//go:build !linux
+
+package signerd
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// peerCredentials 当前平台未实现对端凭据校验，拒绝所有连接
+func peerCredentials(conn *net.UnixConn) (Peer, error) {
+	return Peer{}, fmt.Errorf("peer credential checks are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,82 @@
+// Package signerd 独立的签名守护进程
+//
+// 守护进程持有商户RSA私钥，通过Unix socket应答签名、验签、公钥查询及密钥导入请求，私钥不离开该进程。
+// 每个连接建立时校验对端进程的凭据（Linux SO_PEERCRED），只有允许的uid可以访问。
+//
+// 协议为按行分隔的JSON：客户端每行写入一个 Request，服务端按顺序每行返回一个 Response。
+// 签名原文与汇付规范一致，由调用方使用 huifusign.Canonicalize 生成。
+package signerd
+
+import (
+	"errors"
+	"fmt"
+
+	"huifu-config-system/huifusign"
+)
+
+// 请求类型
+const (
+	OpSign   = "sign"   // 用sys_id的私钥对原文签名
+	OpVerify = "verify" // 用汇付公钥校验响应签名
+	OpImport = "import" // 导入sys_id的私钥（守护进程允许导入时）
+	OpRemove = "remove" // 删除sys_id的私钥
+
+	OpPublicKey = "pubkey" // 查询sys_id私钥对应的公钥
+)
+
+// 错误码
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeUnknownSysID   = "unknown_sys_id"
+	CodeMismatch       = "signature_mismatch"
+	CodeForbidden      = "forbidden"
+	CodeInternal       = "internal"
+)
+
+// Request 签名请求
+type Request struct {
+	Op         string `json:"op"`
+	SysID      string `json:"sys_id,omitempty"`
+	Content    []byte `json:"content,omitempty"` // 签名原文（JSON中为base64）
+	Sign       string `json:"sign,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+}
+
+// Response 签名响应，Code非空表示失败
+type Response struct {
+	Sign      string `json:"sign,omitempty"`
+	PublicKey string `json:"public_key,omitempty"` // 不带标记的base64 PKIX DER
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Peer 连接对端进程的凭据
+type Peer struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+// Error 守护进程返回的错误
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("signer %s: %s", e.Code, e.Message)
+}
+
+// Unwrap 验签失败时可用 errors.Is(err, huifusign.ErrSignatureMismatch) 判断
+func (e *Error) Unwrap() error {
+	if e.Code == CodeMismatch {
+		return huifusign.ErrSignatureMismatch
+	}
+	return nil
+}
+
+// IsUnknownSysID 判断错误是否为守护进程中没有sys_id的私钥
+func IsUnknownSysID(err error) bool {
+	var signerErr *Error
+	return errors.As(err, &signerErr) && signerErr.Code == CodeUnknownSysID
+}
@@ -0,0 +1,195 @@
+package signerd
+
+import (
+	"bufio"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"huifu-config-system/huifusign"
+)
+
+// maxRequestSize 单个请求的最大字节数
+const maxRequestSize = 1 << 20
+
+// Server 签名守护进程
+type Server struct {
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PrivateKey
+	huifuKey    *rsa.PublicKey
+	allowedUIDs map[uint32]bool
+	allowImport bool
+}
+
+// NewServer 创建签名服务；allowedUIDs 为可连接的对端uid，allowImport 控制是否接受密钥导入
+func NewServer(huifuKey *rsa.PublicKey, allowedUIDs []uint32, allowImport bool) *Server {
+	allowed := make(map[uint32]bool, len(allowedUIDs))
+	for _, uid := range allowedUIDs {
+		allowed[uid] = true
+	}
+	return &Server{
+		keys:        make(map[string]*rsa.PrivateKey),
+		huifuKey:    huifuKey,
+		allowedUIDs: allowed,
+		allowImport: allowImport,
+	}
+}
+
+// AddKey 登记sys_id的私钥（PEM或base64 DER）
+func (s *Server) AddKey(sysID, privateKey string) error {
+	if sysID == "" {
+		return fmt.Errorf("sys_id is required")
+	}
+	key, err := huifusign.ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[sysID] = key
+	return nil
+}
+
+// Listen 在path上创建Unix socket，清除残留的socket文件，权限为0660
+func Listen(path string) (*net.UnixListener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %v", err)
+	}
+	return listener, nil
+}
+
+// Serve 接受连接直到listener关闭
+func (s *Server) Serve(listener *net.UnixListener) error {
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn 校验对端凭据后按行处理请求
+func (s *Server) serveConn(conn *net.UnixConn) {
+	defer conn.Close()
+
+	peer, err := peerCredentials(conn)
+	if err != nil {
+		log.Printf("signerd: rejected connection: %v", err)
+		writeResponse(conn, Response{Code: CodeForbidden, Error: err.Error()})
+		return
+	}
+	if !s.allowedUIDs[peer.UID] {
+		log.Printf("signerd: rejected connection from uid=%d pid=%d", peer.UID, peer.PID)
+		writeResponse(conn, Response{Code: CodeForbidden, Error: fmt.Sprintf("uid %d is not allowed", peer.UID)})
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRequestSize)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			writeResponse(conn, Response{Code: CodeInvalidRequest, Error: "request is not valid JSON"})
+			return
+		}
+		resp := s.handle(&req)
+		if resp.Code != "" && resp.Code != CodeMismatch {
+			log.Printf("signerd: %s for sys_id=%s from pid=%d failed: %s", req.Op, req.SysID, peer.PID, resp.Error)
+		}
+		if err := writeResponse(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle 处理单个请求
+func (s *Server) handle(req *Request) Response {
+	switch req.Op {
+	case OpSign:
+		key, exists := s.key(req.SysID)
+		if !exists {
+			return Response{Code: CodeUnknownSysID, Error: fmt.Sprintf("no key for sys_id %s", req.SysID)}
+		}
+		sign, err := huifusign.Sign(key, req.Content)
+		if err != nil {
+			return Response{Code: CodeInternal, Error: err.Error()}
+		}
+		return Response{Sign: sign}
+
+	case OpPublicKey:
+		key, exists := s.key(req.SysID)
+		if !exists {
+			return Response{Code: CodeUnknownSysID, Error: fmt.Sprintf("no key for sys_id %s", req.SysID)}
+		}
+		publicKey, err := huifusign.EncodePublicKey(&key.PublicKey)
+		if err != nil {
+			return Response{Code: CodeInternal, Error: err.Error()}
+		}
+		return Response{PublicKey: publicKey}
+
+	case OpVerify:
+		if err := huifusign.Verify(s.huifuKey, req.Content, req.Sign); err != nil {
+			return Response{Code: CodeMismatch, Error: err.Error()}
+		}
+		return Response{}
+
+	case OpImport:
+		if !s.allowImport {
+			return Response{Code: CodeForbidden, Error: "key import is disabled"}
+		}
+		if err := s.AddKey(req.SysID, req.PrivateKey); err != nil {
+			return Response{Code: CodeInvalidRequest, Error: err.Error()}
+		}
+		log.Printf("signerd: imported key for sys_id=%s", req.SysID)
+		return Response{}
+
+	case OpRemove:
+		if !s.allowImport {
+			return Response{Code: CodeForbidden, Error: "key removal is disabled"}
+		}
+		s.mu.Lock()
+		delete(s.keys, req.SysID)
+		s.mu.Unlock()
+		log.Printf("signerd: removed key for sys_id=%s", req.SysID)
+		return Response{}
+	}
+	return Response{Code: CodeInvalidRequest, Error: fmt.Sprintf("unknown op %q", req.Op)}
+}
+
+// key 返回sys_id的私钥
+func (s *Server) key(sysID string) (*rsa.PrivateKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, exists := s.keys[sysID]
+	return key, exists
+}
+
+func writeResponse(conn net.Conn, resp Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,347 @@
+// Package sim 本地汇付v2接口模拟服务
+//
+// 按汇付v2 HTTP协议接收 {sys_id, product_id, data, sign} 请求，使用登记的商户公钥验签，
+// 并以模拟服务的汇付私钥对响应签名，可独立运行（cmd/huifu-sim）或在测试中嵌入：
+//
+//	server, _ := sim.New(nil)
+//	server.AddMerchant(sysID, productID, merchantPublicKey)
+//	ts := httptest.NewServer(server)
+package sim
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"huifu-config-system/huifusign"
+)
+
+// 模拟服务使用的响应码
+const (
+	RespSuccess          = "00000000"
+	RespInvalidRequest   = "10000000"
+	RespSignatureFailed  = "10000001"
+	RespUnknownSysID     = "10000002"
+	RespUnknownProductID = "10000003"
+	RespUnknownEndpoint  = "10000004"
+)
+
+// Merchant 已登记的商户（sys_id）
+type Merchant struct {
+	SysID     string `json:"sys_id"`
+	ProductID string `json:"product_id"`
+	PublicKey string `json:"public_key"`
+
+	key *rsa.PublicKey
+}
+
+// Request 已验签的接口请求
+type Request struct {
+	Path      string
+	SysID     string
+	ProductID string
+	Data      map[string]interface{}
+}
+
+// HandlerFunc 接口处理函数，返回响应data（未设置resp_code时视为成功）
+type HandlerFunc func(s *Server, req *Request) map[string]interface{}
+
+// Server 汇付接口模拟服务
+type Server struct {
+	mu        sync.RWMutex
+	key       *rsa.PrivateKey
+	merchants map[string]*Merchant
+	handlers  map[string]HandlerFunc
+	wxConfigs map[string][]map[string]interface{} // huifu_id -> 微信配置列表
+	engine    *gin.Engine
+}
+
+// New 创建模拟服务，huifuKey为nil时生成新的汇付私钥
+func New(huifuKey *rsa.PrivateKey) (*Server, error) {
+	if huifuKey == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate huifu key: %v", err)
+		}
+		huifuKey = key
+	}
+
+	s := &Server{
+		key:       huifuKey,
+		merchants: make(map[string]*Merchant),
+		handlers:  make(map[string]HandlerFunc),
+		wxConfigs: make(map[string][]map[string]interface{}),
+	}
+	s.Handle("/v2/merchant/busi/config", handleBusiConfig)
+	s.Handle("/v2/merchant/busi/config/query", handleBusiConfigQuery)
+	s.Handle("/v2/merchant/basicdata/query", handleBasicdataQuery)
+
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.GET("/sim/merchants", s.listMerchants)
+	engine.POST("/sim/merchants", s.addMerchant)
+	engine.POST("/v2/*endpoint", s.serveAPI)
+	s.engine = engine
+	return s, nil
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.engine.ServeHTTP(w, r)
+}
+
+// HuifuPublicKey 返回模拟服务的汇付公钥，用作SDK配置中的 rsa_huifu_public_key
+func (s *Server) HuifuPublicKey() string {
+	encoded, err := huifusign.EncodePublicKey(&s.key.PublicKey)
+	if err != nil {
+		// 合法的RSA公钥总能编码
+		panic(err)
+	}
+	return encoded
+}
+
+// AddMerchant 登记商户及其公钥，重复登记时覆盖
+func (s *Server) AddMerchant(sysID, productID, publicKey string) error {
+	key, err := huifusign.ParsePublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key for sys_id %s: %v", sysID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.merchants[sysID] = &Merchant{
+		SysID:     sysID,
+		ProductID: productID,
+		PublicKey: publicKey,
+		key:       key,
+	}
+	return nil
+}
+
+// Handle 注册（或替换）接口处理函数
+func (s *Server) Handle(path string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[path] = handler
+}
+
+// serveAPI 处理汇付v2接口请求
+func (s *Server) serveAPI(c *gin.Context) {
+	path := "/v2" + c.Param("endpoint")
+
+	var envelope struct {
+		SysID     string          `json:"sys_id"`
+		ProductID string          `json:"product_id"`
+		Data      json.RawMessage `json:"data"`
+		Sign      string          `json:"sign"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&envelope); err != nil {
+		s.respond(c, http.StatusBadRequest, failure(RespInvalidRequest, "请求报文格式错误"))
+		return
+	}
+
+	// data 可以是JSON对象，也可以是JSON字符串（此时直接对字符串内容验签）
+	content, data, err := huifusign.ParseData(envelope.Data)
+	if err != nil {
+		s.respond(c, http.StatusBadRequest, failure(RespInvalidRequest, "data格式错误"))
+		return
+	}
+
+	s.mu.RLock()
+	merchant, exists := s.merchants[envelope.SysID]
+	handler, handled := s.handlers[path]
+	s.mu.RUnlock()
+
+	switch {
+	case !exists:
+		s.respond(c, http.StatusOK, failure(RespUnknownSysID, "sys_id不存在"))
+		return
+	case merchant.ProductID != "" && merchant.ProductID != envelope.ProductID:
+		s.respond(c, http.StatusOK, failure(RespUnknownProductID, "产品号不存在"))
+		return
+	}
+	if err := huifusign.Verify(merchant.key, content, envelope.Sign); err != nil {
+		log.Printf("sim: signature verification failed for sys_id=%s %s: %v", envelope.SysID, path, err)
+		s.respond(c, http.StatusOK, failure(RespSignatureFailed, "验签失败"))
+		return
+	}
+	if !handled {
+		s.respond(c, http.StatusNotFound, failure(RespUnknownEndpoint, "接口不存在"))
+		return
+	}
+
+	result := handler(s, &Request{
+		Path:      path,
+		SysID:     envelope.SysID,
+		ProductID: envelope.ProductID,
+		Data:      data,
+	})
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	if _, ok := result["resp_code"]; !ok {
+		result["resp_code"] = RespSuccess
+		result["resp_desc"] = "交易成功"
+	}
+	for _, key := range []string{"req_seq_id", "req_date"} {
+		if _, ok := result[key]; !ok && data[key] != nil {
+			result[key] = data[key]
+		}
+	}
+	s.respond(c, http.StatusOK, result)
+}
+
+// respond 对响应data签名后输出
+func (s *Server) respond(c *gin.Context, status int, data map[string]interface{}) {
+	content, err := huifusign.Canonicalize(data)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	sign, err := huifusign.Sign(s.key, content)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// 按签名原文原样输出data，避免再次编码改变字节
+	var body bytes.Buffer
+	body.WriteString(`{"data":`)
+	body.Write(content)
+	body.WriteString(`,"sign":`)
+	quoted, _ := json.Marshal(sign)
+	body.Write(quoted)
+	body.WriteString("}")
+	c.Data(status, "application/json; charset=utf-8", body.Bytes())
+}
+
+func failure(code, desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"resp_code": code,
+		"resp_desc": desc,
+	}
+}
+
+// listMerchants 列出已登记的商户
+func (s *Server) listMerchants(c *gin.Context) {
+	s.mu.RLock()
+	merchants := make([]*Merchant, 0, len(s.merchants))
+	for _, merchant := range s.merchants {
+		merchants = append(merchants, merchant)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(merchants, func(i, j int) bool { return merchants[i].SysID < merchants[j].SysID })
+	c.JSON(http.StatusOK, gin.H{
+		"merchants":        merchants,
+		"huifu_public_key": s.HuifuPublicKey(),
+	})
+}
+
+// addMerchant 登记商户公钥
+func (s *Server) addMerchant(c *gin.Context) {
+	var merchant Merchant
+	if err := c.ShouldBindJSON(&merchant); err != nil || merchant.SysID == "" || merchant.PublicKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": "sys_id and public_key are required",
+		})
+		return
+	}
+	if err := s.AddMerchant(merchant.SysID, merchant.ProductID, merchant.PublicKey); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid public key",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Merchant registered",
+		"sys_id":  merchant.SysID,
+	})
+}
+
+// handleBusiConfig 保存微信配置，同一huifu_id下相同fee_type的配置被覆盖
+func handleBusiConfig(s *Server, req *Request) map[string]interface{} {
+	huifuID, _ := req.Data["huifu_id"].(string)
+	if huifuID == "" {
+		return failure(RespInvalidRequest, "huifu_id不能为空")
+	}
+
+	item := make(map[string]interface{})
+	for k, v := range req.Data {
+		switch k {
+		case "huifu_id", "req_seq_id", "req_date":
+		default:
+			item[k] = v
+		}
+	}
+
+	s.mu.Lock()
+	configs := s.wxConfigs[huifuID]
+	replaced := false
+	for i, existing := range configs {
+		if existing["fee_type"] == item["fee_type"] {
+			configs[i] = item
+			replaced = true
+		}
+	}
+	if !replaced {
+		configs = append(configs, item)
+	}
+	s.wxConfigs[huifuID] = configs
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"huifu_id":    huifuID,
+		"config_time": time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// handleBusiConfigQuery 返回huifu_id的微信配置，wx_conf_list 按汇付格式以JSON字符串返回
+func handleBusiConfigQuery(s *Server, req *Request) map[string]interface{} {
+	huifuID, _ := req.Data["huifu_id"].(string)
+	if huifuID == "" {
+		return failure(RespInvalidRequest, "huifu_id不能为空")
+	}
+
+	s.mu.RLock()
+	list, err := json.Marshal(s.wxConfigs[huifuID])
+	s.mu.RUnlock()
+	if err != nil {
+		return failure(RespInvalidRequest, err.Error())
+	}
+	if string(list) == "null" {
+		list = []byte("[]")
+	}
+
+	return map[string]interface{}{
+		"huifu_id":     huifuID,
+		"wx_conf_list": string(list),
+	}
+}
+
+// handleBasicdataQuery 返回商户基本信息，huifu_id为空时查询sys_id本身
+func handleBasicdataQuery(s *Server, req *Request) map[string]interface{} {
+	huifuID, _ := req.Data["huifu_id"].(string)
+	if huifuID == "" {
+		huifuID = req.SysID
+	}
+	return map[string]interface{}{
+		"huifu_id":       huifuID,
+		"reg_name":       "模拟商户" + huifuID,
+		"short_name":     "模拟商户",
+		"ent_type":       "1",
+		"upper_huifu_id": req.SysID,
+		"status":         "ACTIVE",
+	}
+}
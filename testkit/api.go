@@ -0,0 +1,412 @@
+package testkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"huifu-config-system/huifusign"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxKeyFileSize 上传私钥文件的大小上限，与真实服务一致
+const maxKeyFileSize = 64 << 10
+
+// routes 与真实服务一致的接口路径及响应格式
+func (k *Kit) routes() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	api := r.Group("/api")
+	{
+		api.POST("/config", k.saveConfig)
+		api.POST("/config/import", k.importConfig)
+		api.DELETE("/config/:sys_id", k.deleteConfig)
+		api.GET("/configs", k.getConfigs)
+		api.POST("/test-config", k.testConfig)
+		api.POST("/wechat-config", k.configureWeChatMerchant)
+		api.POST("/wechat-config-query", k.queryWeChatConfig)
+		api.POST("/call/:sys_id/*endpoint", k.callEndpoint)
+	}
+	return r
+}
+
+func (k *Kit) saveConfig(c *gin.Context) {
+	var req struct {
+		SysID            string `json:"sys_id" binding:"required"`
+		ProductID        string `json:"product_id" binding:"required"`
+		RSAPrivateKey    string `json:"rsa_private_key"`
+		RSAPrivateKeyURI string `json:"rsa_private_key_uri"`
+		Environment      string `json:"environment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	// 与真实服务相同的私钥校验；私钥URI只检查格式，不读取内容
+	if req.RSAPrivateKey == "" && req.RSAPrivateKeyURI == "" {
+		validationFailed(c, "Invalid request", "rsa_private_key", "is required unless rsa_private_key_uri is given or a signer daemon holds the key")
+		return
+	}
+	if req.RSAPrivateKeyURI != "" {
+		if req.RSAPrivateKey != "" {
+			validationFailed(c, "Invalid request", "rsa_private_key_uri", "provide either rsa_private_key or rsa_private_key_uri, not both")
+			return
+		}
+		if !strings.Contains(req.RSAPrivateKeyURI, "://") {
+			validationFailed(c, "Invalid request", "rsa_private_key_uri", "must be a URI such as file:///path, env://NAME or vault://mount/path#field")
+			return
+		}
+	} else if _, err := huifusign.ParsePrivateKey(req.RSAPrivateKey); err != nil {
+		validationFailed(c, "Invalid request", "rsa_private_key", err.Error())
+		return
+	}
+
+	k.seedConfig(Config{SysID: req.SysID, ProductID: req.ProductID, Environment: req.Environment, KeyURI: req.RSAPrivateKeyURI})
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Configuration saved successfully",
+		"sys_id":  req.SysID,
+	})
+}
+
+// importConfig 以上传的私钥文件保存配置，字段及校验与真实服务一致（不检查私钥期限）
+func (k *Kit) importConfig(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxKeyFileSize+64<<10)
+	if err := c.Request.ParseMultipartForm(maxKeyFileSize + 64<<10); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	config := Config{
+		SysID:       strings.TrimSpace(c.PostForm("sys_id")),
+		ProductID:   strings.TrimSpace(c.PostForm("product_id")),
+		Environment: c.PostForm("environment"),
+	}
+	for _, field := range []struct{ name, value string }{{"sys_id", config.SysID}, {"product_id", config.ProductID}} {
+		if field.value == "" {
+			validationFailed(c, "Invalid request", field.name, "is required")
+			return
+		}
+	}
+	if value := c.PostForm("key_created_at"); value != "" {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			validationFailed(c, "Invalid request", "key_created_at", "must be an RFC3339 timestamp")
+			return
+		}
+	}
+
+	header, err := c.FormFile("key_file")
+	if err != nil {
+		validationFailed(c, "Invalid key file", "key_file", "is required")
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		validationFailed(c, "Invalid key file", "key_file", err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxKeyFileSize))
+	if err != nil {
+		validationFailed(c, "Invalid key file", "key_file", err.Error())
+		return
+	}
+
+	bundle, err := huifusign.ParseKeyBundle(data, c.PostForm("passphrase"))
+	if err != nil {
+		field := "key_file"
+		if errors.Is(err, huifusign.ErrIncorrectPassphrase) {
+			field = "passphrase"
+		}
+		validationFailed(c, "Invalid key file", field, err.Error())
+		return
+	}
+	var certificate *CertificateInfo
+	if cert := bundle.Certificate(); cert != nil {
+		now := time.Now()
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			validationFailed(c, "Invalid key file", "key_file", fmt.Sprintf("certificate %q is not valid at %s", cert.Subject.CommonName, now.Format(time.RFC3339)))
+			return
+		}
+		certificate = &CertificateInfo{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+		}
+	}
+
+	k.seedConfig(config)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Configuration saved successfully",
+		"sys_id":      config.SysID,
+		"format":      bundle.Format,
+		"certificate": certificate,
+	})
+}
+
+func (k *Kit) deleteConfig(c *gin.Context) {
+	sysID := c.Param("sys_id")
+
+	k.mu.Lock()
+	_, exists := k.configs[sysID]
+	delete(k.configs, sysID)
+	k.mu.Unlock()
+
+	if !exists {
+		configNotFound(c, sysID)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Configuration deleted successfully",
+		"sys_id":  sysID,
+	})
+}
+
+func (k *Kit) getConfigs(c *gin.Context) {
+	configs := []map[string]string{}
+	for _, config := range k.Configs() {
+		entry := map[string]string{
+			"sys_id":      config.SysID,
+			"product_id":  config.ProductID,
+			"environment": config.Environment,
+			"health":      "unknown",
+			"key_source":  "inline",
+			"key_uri":     config.KeyURI,
+		}
+		if scheme, _, found := strings.Cut(config.KeyURI, "://"); found {
+			entry["key_source"] = scheme
+		}
+		configs = append(configs, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"configs": configs,
+		"count":   len(configs),
+	})
+}
+
+func (k *Kit) testConfig(c *gin.Context) {
+	var req struct {
+		SysID string `json:"sys_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	data, _, ok := k.call(c, req.SysID, "/v2/merchant/basicdata/query", map[string]interface{}{}, "Configuration test failed")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Configuration is valid",
+		"status":   "success",
+		"merchant": data,
+	})
+}
+
+func (k *Kit) configureWeChatMerchant(c *gin.Context) {
+	params, sysID, ok := k.bindParams(c, "fee_type")
+	if !ok {
+		return
+	}
+
+	data, replayed, ok := k.call(c, sysID, "/v2/merchant/busi/config", params, "Failed to configure WeChat merchant")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    data,
+		"huifu_id":   params["huifu_id"],
+		"wx_app_id":  params["wx_woa_app_id"],
+		"req_seq_id": params["req_seq_id"],
+		"replayed":   replayed,
+	})
+}
+
+func (k *Kit) queryWeChatConfig(c *gin.Context) {
+	params, sysID, ok := k.bindParams(c)
+	if !ok {
+		return
+	}
+
+	data, replayed, ok := k.call(c, sysID, "/v2/merchant/busi/config/query", params, "Failed to query WeChat merchant config")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    data,
+		"huifu_id":   params["huifu_id"],
+		"req_seq_id": params["req_seq_id"],
+		"replayed":   replayed,
+	})
+}
+
+func (k *Kit) callEndpoint(c *gin.Context) {
+	sysID := c.Param("sys_id")
+	endpoint := c.Param("endpoint")
+	if !strings.HasPrefix(endpoint, "/v2/") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Endpoint not found",
+			"details": fmt.Sprintf("unknown endpoint: %s", endpoint),
+		})
+		return
+	}
+
+	params, err := decodeParams(c)
+	if err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	data, replayed, ok := k.call(c, sysID, endpoint, params, fmt.Sprintf("Call to %s failed", endpoint))
+	if !ok {
+		return
+	}
+	body := gin.H{
+		"endpoint":  endpoint,
+		"sys_id":    sysID,
+		"resp_code": data["resp_code"],
+		"resp_desc": data["resp_desc"],
+		"data":      data,
+	}
+	if reqSeqID := stringParam(data, "req_seq_id"); reqSeqID != "" {
+		body["req_seq_id"] = reqSeqID
+	}
+	if replayed {
+		body["replayed"] = true
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// bindParams 解析请求体：校验sys_id、huifu_id及必填字段，取出sys_id
+func (k *Kit) bindParams(c *gin.Context, required ...string) (map[string]interface{}, string, bool) {
+	params, err := decodeParams(c)
+	if err != nil {
+		invalidRequest(c, err)
+		return nil, "", false
+	}
+
+	for _, field := range append([]string{"sys_id", "huifu_id"}, required...) {
+		if stringParam(params, field) == "" {
+			validationFailed(c, "Invalid request", field, "is required")
+			return nil, "", false
+		}
+	}
+	sysID := stringParam(params, "sys_id")
+	delete(params, "sys_id")
+	return params, sysID, true
+}
+
+// call 通过sys_id的假客户端调用接口，失败时按真实服务的错误格式响应
+// 调用方给出req_seq_id时与真实服务一致：相同请求返回已有结果（replayed为true），不同请求返回409；未给出时自动生成流水号
+func (k *Kit) call(c *gin.Context, sysID, endpoint string, params map[string]interface{}, message string) (map[string]interface{}, bool, bool) {
+	k.mu.RLock()
+	_, exists := k.configs[sysID]
+	k.mu.RUnlock()
+	if !exists {
+		configNotFound(c, sysID)
+		return nil, false, false
+	}
+
+	data, err := k.replay(sysID, endpoint, params)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      message,
+			"details":    err.Error(),
+			"category":   "conflict",
+			"req_seq_id": params["req_seq_id"],
+		})
+		return nil, false, false
+	}
+	replayed := data != nil
+
+	if !replayed {
+		k.stampReqSeq(params)
+		result, err := k.Client(sysID).CallAPI(c.Request.Context(), endpoint, params)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":    message,
+				"details":  fmt.Sprintf("huifu network error on %s: %v", endpoint, err),
+				"category": "network",
+			})
+			return nil, false, false
+		}
+
+		data, _ = result["data"].(map[string]interface{})
+		if data == nil {
+			data = result
+		}
+		k.remember(sysID, endpoint, params, data)
+	}
+
+	respCode := stringParam(data, "resp_code")
+	if respCode != RespSuccess && respCode != "00000" && respCode != "00000100" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":      message,
+			"details":    fmt.Sprintf("huifu business error on %s: [%s] %s", endpoint, respCode, stringParam(data, "resp_desc")),
+			"category":   "business",
+			"resp_code":  respCode,
+			"resp_desc":  data["resp_desc"],
+			"req_seq_id": data["req_seq_id"],
+		})
+		return nil, false, false
+	}
+	return data, replayed, true
+}
+
+// stampReqSeq 补全请求流水号及日期
+func (k *Kit) stampReqSeq(params map[string]interface{}) {
+	if stringParam(params, "req_seq_id") == "" {
+		k.mu.Lock()
+		k.seq++
+		params["req_seq_id"] = fmt.Sprintf("%s%06d", time.Now().Format("20060102150405"), k.seq)
+		k.mu.Unlock()
+	}
+	if stringParam(params, "req_date") == "" {
+		params["req_date"] = time.Now().Format("20060102")
+	}
+}
+
+// decodeParams 解码请求体为参数map，数字保留为json.Number
+func decodeParams(c *gin.Context) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+
+	params := make(map[string]interface{})
+	if err := decoder.Decode(&params); err != nil {
+		return nil, fmt.Errorf("request body must be a JSON object: %v", err)
+	}
+	return params, nil
+}
+
+// validationFailed 按真实服务的参数校验错误格式响应
+func validationFailed(c *gin.Context, message, field, reason string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":    message,
+		"details":  fmt.Sprintf("invalid %s: %s", field, reason),
+		"category": "validation",
+		"field":    field,
+	})
+}
+
+func invalidRequest(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid request",
+		"details": err.Error(),
+	})
+}
+
+func configNotFound(c *gin.Context, sysID string) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":   "Configuration not found",
+		"details": fmt.Sprintf("configuration not found for sys_id: %s", sysID),
+	})
+}
@@ -0,0 +1,79 @@
+package testkit
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Calls 返回记录的全部汇付调用（按调用顺序）
+func (k *Kit) Calls() []Call {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return append([]Call{}, k.calls...)
+}
+
+// CallsTo 返回对endpoint的调用
+func (k *Kit) CallsTo(endpoint string) []Call {
+	var calls []Call
+	for _, call := range k.Calls() {
+		if call.Endpoint == endpoint {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// AssertCalled 断言endpoint被调用过，且至少一次调用的参数包含params（按字符串形式比较）
+func (k *Kit) AssertCalled(t testing.TB, endpoint string, params map[string]interface{}) Call {
+	t.Helper()
+
+	calls := k.CallsTo(endpoint)
+	for _, call := range calls {
+		if call.matches(params) {
+			return call
+		}
+	}
+	if len(calls) == 0 {
+		t.Fatalf("expected a call to %s, got none", endpoint)
+	} else {
+		t.Fatalf("expected a call to %s with params %v, got %d call(s) with params %v", endpoint, params, len(calls), paramsOf(calls))
+	}
+	return Call{}
+}
+
+// AssertNotCalled 断言endpoint未被调用
+func (k *Kit) AssertNotCalled(t testing.TB, endpoint string) {
+	t.Helper()
+
+	if calls := k.CallsTo(endpoint); len(calls) > 0 {
+		t.Fatalf("expected no call to %s, got %d call(s) with params %v", endpoint, len(calls), paramsOf(calls))
+	}
+}
+
+// AssertCallCount 断言endpoint被调用的次数
+func (k *Kit) AssertCallCount(t testing.TB, endpoint string, want int) {
+	t.Helper()
+
+	if got := len(k.CallsTo(endpoint)); got != want {
+		t.Fatalf("expected %d call(s) to %s, got %d", want, endpoint, got)
+	}
+}
+
+// matches 判断调用参数是否包含params
+func (c *Call) matches(params map[string]interface{}) bool {
+	for key, want := range params {
+		got, present := c.Params[key]
+		if !present || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func paramsOf(calls []Call) []map[string]interface{} {
+	params := make([]map[string]interface{}, len(calls))
+	for i, call := range calls {
+		params[i] = call.Params
+	}
+	return params
+}
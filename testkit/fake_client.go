@@ -0,0 +1,210 @@
+package testkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 假客户端使用的响应码
+const (
+	RespSuccess          = "00000000"
+	RespMerchantNotFound = "10000005"
+)
+
+// Request 一次汇付接口调用
+type Request struct {
+	SysID    string
+	Endpoint string
+	Params   map[string]interface{}
+}
+
+// Call 记录的调用及其结果
+type Call struct {
+	Request
+	Response map[string]interface{}
+	Err      error
+	At       time.Time
+}
+
+// HandlerFunc 自定义接口响应；返回error模拟网络等传输层失败
+type HandlerFunc func(req *Request) (map[string]interface{}, error)
+
+// FakeClient 某个sys_id的假汇付客户端
+// 方法集与服务端的 HuifuClient 接口一致，可直接替换真实客户端
+type FakeClient struct {
+	kit   *Kit
+	sysID string
+}
+
+// CallAPI 按已配置的响应返回结果并记录调用
+func (c *FakeClient) CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	req := &Request{SysID: c.sysID, Endpoint: endpoint, Params: copyParams(params)}
+
+	var result map[string]interface{}
+	err := ctx.Err()
+	if err == nil {
+		result, err = c.kit.handler(endpoint)(req)
+	}
+
+	c.kit.mu.Lock()
+	c.kit.calls = append(c.kit.calls, Call{Request: *req, Response: result, Err: err, At: time.Now()})
+	c.kit.mu.Unlock()
+	return result, err
+}
+
+// Respond 使endpoint返回成功响应，data中未给出的resp_code及流水号自动补全
+func (k *Kit) Respond(endpoint string, data map[string]interface{}) {
+	k.Handle(endpoint, func(req *Request) (map[string]interface{}, error) {
+		return success(req, data), nil
+	})
+}
+
+// Fail 使endpoint返回汇付业务失败
+func (k *Kit) Fail(endpoint, respCode, respDesc string) {
+	k.Handle(endpoint, func(req *Request) (map[string]interface{}, error) {
+		return failure(req, respCode, respDesc), nil
+	})
+}
+
+// Error 使endpoint返回传输层错误（如超时、连接失败）
+func (k *Kit) Error(endpoint string, err error) {
+	k.Handle(endpoint, func(req *Request) (map[string]interface{}, error) {
+		return nil, err
+	})
+}
+
+// Handle 自定义endpoint的响应，endpoint为 "*" 时匹配所有未单独配置的接口
+func (k *Kit) Handle(endpoint string, handler HandlerFunc) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stubs[endpoint] = handler
+}
+
+// handler 选择endpoint的响应：单独配置 > "*" > 默认响应
+func (k *Kit) handler(endpoint string) HandlerFunc {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if handler, ok := k.stubs[endpoint]; ok {
+		return handler
+	}
+	if handler, ok := k.stubs["*"]; ok {
+		return handler
+	}
+	switch endpoint {
+	case "/v2/merchant/busi/config":
+		return k.busiConfig
+	case "/v2/merchant/busi/config/query":
+		return k.busiConfigQuery
+	case "/v2/merchant/basicdata/query":
+		return k.basicdataQuery
+	}
+	return func(req *Request) (map[string]interface{}, error) {
+		return success(req, nil), nil
+	}
+}
+
+// busiConfig 默认的微信配置写入：记入商户状态，后续查询可见；未知商户返回商户不存在
+func (k *Kit) busiConfig(req *Request) (map[string]interface{}, error) {
+	huifuID := stringParam(req.Params, "huifu_id")
+	item := WxConf{
+		FeeType:          stringParam(req.Params, "fee_type"),
+		WxWoaAppID:       stringParam(req.Params, "wx_woa_app_id"),
+		WxWoaPath:        stringParam(req.Params, "wx_woa_path"),
+		WxAppletAppID:    stringParam(req.Params, "wx_applet_app_id"),
+		WxSubscribeAppID: stringParam(req.Params, "wx_subscribe_app_id"),
+	}
+	if !k.applyConfig(huifuID, item) {
+		return failure(req, RespMerchantNotFound, "商户不存在"), nil
+	}
+
+	return success(req, map[string]interface{}{
+		"huifu_id":      huifuID,
+		"fee_type":      item.FeeType,
+		"wx_woa_app_id": item.WxWoaAppID,
+		"wx_woa_path":   item.WxWoaPath,
+		"config_status": "SUCCESS",
+	}), nil
+}
+
+// busiConfigQuery 默认的微信配置查询，与汇付一致 wx_conf_list 以JSON字符串返回
+func (k *Kit) busiConfigQuery(req *Request) (map[string]interface{}, error) {
+	huifuID := stringParam(req.Params, "huifu_id")
+	merchant, exists := k.Merchant(huifuID)
+	if !exists {
+		return failure(req, RespMerchantNotFound, "商户不存在"), nil
+	}
+
+	list := merchant.WxConfList
+	if list == nil {
+		list = []WxConf{}
+	}
+	encoded, err := json.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode wx_conf_list: %v", err)
+	}
+	return success(req, map[string]interface{}{
+		"huifu_id":     huifuID,
+		"wx_conf_list": string(encoded),
+	}), nil
+}
+
+// basicdataQuery 默认的商户基本信息查询，huifu_id 缺省为sys_id
+func (k *Kit) basicdataQuery(req *Request) (map[string]interface{}, error) {
+	huifuID := stringParam(req.Params, "huifu_id")
+	if huifuID == "" {
+		huifuID = req.SysID
+	}
+	merchant, exists := k.Merchant(huifuID)
+	if !exists {
+		return failure(req, RespMerchantNotFound, "商户不存在"), nil
+	}
+
+	return success(req, map[string]interface{}{
+		"huifu_id":       merchant.HuifuID,
+		"reg_name":       merchant.RegName,
+		"short_name":     merchant.RegName,
+		"ent_type":       "1",
+		"upper_huifu_id": req.SysID,
+		"status":         "ACTIVE",
+	}), nil
+}
+
+// success 组装成功响应，补全resp_code与请求流水号
+func success(req *Request, data map[string]interface{}) map[string]interface{} {
+	response := copyParams(data)
+	if _, ok := response["resp_code"]; !ok {
+		response["resp_code"] = RespSuccess
+		response["resp_desc"] = "交易成功"
+	}
+	for _, key := range []string{"req_seq_id", "req_date"} {
+		if _, ok := response[key]; !ok && req.Params[key] != nil {
+			response[key] = req.Params[key]
+		}
+	}
+	return map[string]interface{}{"data": response}
+}
+
+func failure(req *Request, respCode, respDesc string) map[string]interface{} {
+	return success(req, map[string]interface{}{
+		"resp_code": respCode,
+		"resp_desc": respDesc,
+	})
+}
+
+func copyParams(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	return copied
+}
+
+func stringParam(params map[string]interface{}, key string) string {
+	if value, ok := params[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// seqRecord 已得到汇付响应的请求，按req_seq_id保存
+type seqRecord struct {
+	sysID    string
+	endpoint string
+	reqDate  string
+	params   string
+	data     map[string]interface{}
+}
+
+// replay 查找调用方提供的req_seq_id对应的已有结果，规则与真实服务的调用日志一致：
+// 请求一致时返回存储的结果，sys_id、接口、req_date或参数不一致时返回冲突错误，无记录时返回 (nil, nil)
+func (k *Kit) replay(sysID, endpoint string, params map[string]interface{}) (map[string]interface{}, error) {
+	reqSeqID := stringParam(params, "req_seq_id")
+	if reqSeqID == "" {
+		return nil, nil
+	}
+
+	k.mu.RLock()
+	record, exists := k.seqs[reqSeqID]
+	k.mu.RUnlock()
+	if !exists {
+		return nil, nil
+	}
+
+	switch {
+	case record.sysID != sysID:
+		return nil, fmt.Errorf("req_seq_id %s already used: used by another sys_id", reqSeqID)
+	case record.endpoint != endpoint:
+		return nil, fmt.Errorf("req_seq_id %s already used: used for %s", reqSeqID, record.endpoint)
+	}
+	if reqDate := stringParam(params, "req_date"); reqDate != "" && reqDate != record.reqDate {
+		return nil, fmt.Errorf("req_seq_id %s already used: used with req_date %s", reqSeqID, record.reqDate)
+	}
+	if record.params != canonicalParams(params) {
+		return nil, fmt.Errorf("req_seq_id %s already used: used with different parameters", reqSeqID)
+	}
+	return copyParams(record.data), nil
+}
+
+// remember 保存得到汇付响应的请求，传输层失败的请求不保存，可用同一流水号重试
+func (k *Kit) remember(sysID, endpoint string, params, data map[string]interface{}) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.seqs[stringParam(params, "req_seq_id")] = &seqRecord{
+		sysID:    sysID,
+		endpoint: endpoint,
+		reqDate:  stringParam(params, "req_date"),
+		params:   canonicalParams(params),
+		data:     copyParams(data),
+	}
+}
+
+// canonicalParams 忽略流水号字段后的规范化JSON
+func canonicalParams(params map[string]interface{}) string {
+	stripped := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if k != "req_seq_id" && k != "req_date" {
+			stripped[k] = v
+		}
+	}
+	data, _ := json.Marshal(stripped)
+	return string(data)
+}
@@ -0,0 +1,207 @@
+// Package testkit 供集成本系统的服务编写测试
+//
+// Kit 在进程内提供与本系统一致的HTTP接口（配置管理、微信商户配置与查询、通用接口调用），
+// 背后是可配置的假汇付客户端，并记录每一次汇付调用以便断言。无需网络、密钥或汇付账号：
+//
+//	kit := testkit.New(t)
+//	kit.SeedConfig("6666000123456789", "PAYUN", "test")
+//	kit.Fail("/v2/merchant/busi/config", "10000000", "该费率类型不支持配置公众号")
+//
+//	svc := mypkg.NewService(kit.URL) // 或用 kit.Handler() 配合 httptest.NewRecorder
+//	...
+//	kit.AssertCalled(t, "/v2/merchant/busi/config", map[string]interface{}{"fee_type": "02"})
+//
+// 需要直接使用 HuifuClient 的代码可通过 kit.Client(sysID) 取得假客户端。
+//
+// 状态码、响应字段、参数校验、req_seq_id 重放与冲突（409）及未知商户的处理与真实服务一致，
+// 由服务端的 testkit_contract_test.go 对同一组请求比较两者。其余差异：
+//   - 只提供上面列出的接口，不提供公钥、健康检查、调用记录、TOTP及管理接口
+//   - 不做TOTP二次验证，/api/call 不检查接口白名单及写接口的 X-Operator-ID，接受任意 /v2 接口路径
+//   - 不经过重试、限流、熔断及私钥期限检查，不计入调用统计与审计
+//   - 微信配置请求只校验必填字段，不校验 huifu_id、fee_type、AppID 等的取值格式
+//   - /api/config/import 按已配置签名器的部署处理，真实服务未配置签名器时拒绝导入（403）
+//   - rsa_private_key_uri 只检查格式，不检查 HUIFU_SECRET_ALLOW_* 白名单，也不读取私钥；/api/configs 的 health 恒为 unknown，不返回私钥期限字段（key_created_at 等），也不校验提交的 key_created_at
+//   - 同一 req_seq_id 的并发请求不排队，重放只对经HTTP接口的调用生效，kit.Client 的调用不记录流水号
+package testkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config 已登记的系统配置
+type Config struct {
+	SysID       string `json:"sys_id"`
+	ProductID   string `json:"product_id"`
+	Environment string `json:"environment"`
+	KeyURI      string `json:"key_uri,omitempty"` // rsa_private_key_uri，直接提交私钥时为空
+}
+
+// CertificateInfo 导入的证书信息，与真实服务 /api/config/import 的响应一致
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+}
+
+// WxConf 单条微信配置（wx_conf_list 中的元素）
+type WxConf struct {
+	FeeType          string `json:"fee_type,omitempty"`
+	WxWoaAppID       string `json:"wx_woa_app_id,omitempty"`
+	WxWoaPath        string `json:"wx_woa_path,omitempty"`
+	WxAppletAppID    string `json:"wx_applet_app_id,omitempty"`
+	WxSubscribeAppID string `json:"wx_subscribe_app_id,omitempty"`
+}
+
+// Merchant 假客户端中的商户（huifu_id）状态
+type Merchant struct {
+	HuifuID    string   `json:"huifu_id"`
+	RegName    string   `json:"reg_name"`
+	WxConfList []WxConf `json:"wx_conf_list"`
+}
+
+// Kit 进程内的测试服务
+type Kit struct {
+	// URL 测试服务地址（httptest，仅监听本机回环地址）
+	URL string
+
+	mu        sync.RWMutex
+	engine    *gin.Engine
+	configs   map[string]*Config
+	merchants map[string]*Merchant
+	stubs     map[string]HandlerFunc
+	calls     []Call
+	seqs      map[string]*seqRecord
+	seq       int
+}
+
+// New 创建测试服务，测试结束时自动关闭
+func New(t testing.TB) *Kit {
+	t.Helper()
+
+	k := &Kit{
+		configs:   make(map[string]*Config),
+		merchants: make(map[string]*Merchant),
+		stubs:     make(map[string]HandlerFunc),
+		seqs:      make(map[string]*seqRecord),
+	}
+	k.engine = k.routes()
+
+	server := httptest.NewServer(k.engine)
+	t.Cleanup(server.Close)
+	k.URL = server.URL
+	return k
+}
+
+// Handler 返回测试服务的 http.Handler，可不经网络直接调用
+func (k *Kit) Handler() http.Handler {
+	return k.engine
+}
+
+// Client 返回sys_id的假汇付客户端
+func (k *Kit) Client(sysID string) *FakeClient {
+	return &FakeClient{kit: k, sysID: sysID}
+}
+
+// SeedConfig 登记系统配置，sys_id 同时登记为已知商户（与真实服务的连通性测试一致）
+func (k *Kit) SeedConfig(sysID, productID, environment string) {
+	k.seedConfig(Config{SysID: sysID, ProductID: productID, Environment: environment})
+}
+
+func (k *Kit) seedConfig(config Config) {
+	if config.Environment == "" {
+		config.Environment = "test"
+	}
+
+	k.mu.Lock()
+	k.configs[config.SysID] = &config
+	k.mu.Unlock()
+
+	if _, exists := k.Merchant(config.SysID); !exists {
+		k.SeedMerchant(Merchant{HuifuID: config.SysID})
+	}
+}
+
+// SeedMerchant 预置商户状态，已存在的商户被整体替换
+func (k *Kit) SeedMerchant(merchants ...Merchant) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, merchant := range merchants {
+		if merchant.RegName == "" {
+			merchant.RegName = "测试商户" + merchant.HuifuID
+		}
+		merchant.WxConfList = append([]WxConf{}, merchant.WxConfList...)
+		k.merchants[merchant.HuifuID] = &merchant
+	}
+}
+
+// Merchant 返回商户状态的副本
+func (k *Kit) Merchant(huifuID string) (Merchant, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	merchant, exists := k.merchants[huifuID]
+	if !exists {
+		return Merchant{}, false
+	}
+	copied := *merchant
+	copied.WxConfList = append([]WxConf{}, merchant.WxConfList...)
+	return copied, true
+}
+
+// Configs 返回已登记的配置，按sys_id排序
+func (k *Kit) Configs() []Config {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	configs := make([]Config, 0, len(k.configs))
+	for _, config := range k.configs {
+		configs = append(configs, *config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].SysID < configs[j].SysID })
+	return configs
+}
+
+// Reset 清除配置、商户、自定义响应、调用记录及已用的流水号
+func (k *Kit) Reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.configs = make(map[string]*Config)
+	k.merchants = make(map[string]*Merchant)
+	k.stubs = make(map[string]HandlerFunc)
+	k.calls = nil
+	k.seqs = make(map[string]*seqRecord)
+}
+
+// applyConfig 写入一条微信配置：相同fee_type与AppID的配置被更新，否则追加
+// 商户未知时不写入并返回false
+func (k *Kit) applyConfig(huifuID string, item WxConf) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	merchant, exists := k.merchants[huifuID]
+	if !exists {
+		return false
+	}
+	for i, existing := range merchant.WxConfList {
+		if existing.FeeType == item.FeeType &&
+			existing.WxWoaAppID == item.WxWoaAppID &&
+			existing.WxAppletAppID == item.WxAppletAppID &&
+			existing.WxSubscribeAppID == item.WxSubscribeAppID {
+			merchant.WxConfList[i] = item
+			return true
+		}
+	}
+	merchant.WxConfList = append(merchant.WxConfList, item)
+	return true
+}
@@ -0,0 +1,252 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testSysID   = "6666000123456789"
+	testHuifuID = "6666000123456790"
+)
+
+// do 经 kit.Handler() 发送请求，返回状态码及解码后的响应
+func do(t *testing.T, kit *Kit, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	return serve(t, kit, req)
+}
+
+func serve(t *testing.T, kit *Kit, req *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	kit.Handler().ServeHTTP(rec, req)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("%s %s: invalid JSON response %q: %v", req.Method, req.URL.Path, rec.Body.String(), err)
+	}
+	return rec.Code, decoded
+}
+
+func wechatConfig(reqSeqID, appID string) map[string]interface{} {
+	return map[string]interface{}{
+		"sys_id":        testSysID,
+		"huifu_id":      testHuifuID,
+		"fee_type":      "02",
+		"wx_woa_app_id": appID,
+		"wx_woa_path":   "https://example.com/pay/",
+		"req_seq_id":    reqSeqID,
+	}
+}
+
+func TestSeedAndAssertCalled(t *testing.T) {
+	kit := New(t)
+	kit.SeedConfig(testSysID, "PAYUN", "")
+	kit.SeedMerchant(Merchant{HuifuID: testHuifuID})
+
+	if configs := kit.Configs(); len(configs) != 1 || configs[0].Environment != "test" {
+		t.Fatalf("Configs() = %+v, want one test config", configs)
+	}
+
+	// 经 kit.URL 的真实HTTP请求
+	data, _ := json.Marshal(wechatConfig("", "wx0123456789abcdef"))
+	resp, err := http.Post(kit.URL+"/api/wechat-config", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /api/wechat-config = %d, want 200", resp.StatusCode)
+	}
+
+	call := kit.AssertCalled(t, "/v2/merchant/busi/config", map[string]interface{}{
+		"huifu_id":      testHuifuID,
+		"fee_type":      "02",
+		"wx_woa_app_id": "wx0123456789abcdef",
+	})
+	if call.SysID != testSysID || stringParam(call.Params, "req_seq_id") == "" {
+		t.Errorf("recorded call = %+v, want sys_id %s and a generated req_seq_id", call.Request, testSysID)
+	}
+	kit.AssertCallCount(t, "/v2/merchant/busi/config", 1)
+	kit.AssertNotCalled(t, "/v2/merchant/busi/config/query")
+
+	merchant, _ := kit.Merchant(testHuifuID)
+	if len(merchant.WxConfList) != 1 || merchant.WxConfList[0].WxWoaAppID != "wx0123456789abcdef" {
+		t.Fatalf("merchant state = %+v, want the written config", merchant)
+	}
+
+	status, body := do(t, kit, http.MethodPost, "/api/wechat-config-query", map[string]interface{}{
+		"sys_id":   testSysID,
+		"huifu_id": testHuifuID,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("query = %d %v", status, body)
+	}
+	message, _ := body["message"].(map[string]interface{})
+	var list []WxConf
+	if err := json.Unmarshal([]byte(stringParam(message, "wx_conf_list")), &list); err != nil || len(list) != 1 {
+		t.Fatalf("wx_conf_list = %v (%v), want one entry", message["wx_conf_list"], err)
+	}
+}
+
+func TestStubsAndErrors(t *testing.T) {
+	kit := New(t)
+	kit.SeedConfig(testSysID, "PAYUN", "test")
+	kit.SeedMerchant(Merchant{HuifuID: testHuifuID})
+
+	kit.Fail("/v2/merchant/busi/config", "10000000", "该费率类型不支持配置公众号")
+	status, body := do(t, kit, http.MethodPost, "/api/wechat-config", wechatConfig("", "wx0123456789abcdef"))
+	if status != http.StatusUnprocessableEntity || body["category"] != "business" || body["resp_code"] != "10000000" {
+		t.Fatalf("stubbed failure = %d %v, want 422 business 10000000", status, body)
+	}
+
+	kit.Error("/v2/merchant/busi/config/query", errors.New("connection reset"))
+	status, body = do(t, kit, http.MethodPost, "/api/wechat-config-query", map[string]interface{}{
+		"sys_id":   testSysID,
+		"huifu_id": testHuifuID,
+	})
+	if status != http.StatusBadGateway || body["category"] != "network" {
+		t.Fatalf("stubbed network error = %d %v, want 502 network", status, body)
+	}
+
+	status, body = do(t, kit, http.MethodPost, "/api/wechat-config", map[string]interface{}{"sys_id": testSysID, "huifu_id": testHuifuID})
+	if status != http.StatusBadRequest || body["field"] != "fee_type" {
+		t.Fatalf("missing fee_type = %d %v, want 400 field fee_type", status, body)
+	}
+
+	status, _ = do(t, kit, http.MethodPost, "/api/test-config", map[string]interface{}{"sys_id": "6666000000000000"})
+	if status != http.StatusNotFound {
+		t.Fatalf("unknown sys_id = %d, want 404", status)
+	}
+
+	kit.Reset()
+	if len(kit.Calls()) != 0 || len(kit.Configs()) != 0 {
+		t.Fatal("Reset kept calls or configs")
+	}
+}
+
+func TestUnknownMerchantWrite(t *testing.T) {
+	kit := New(t)
+	kit.SeedConfig(testSysID, "PAYUN", "test")
+
+	status, body := do(t, kit, http.MethodPost, "/api/wechat-config", wechatConfig("", "wx0123456789abcdef"))
+	if status != http.StatusUnprocessableEntity || body["resp_code"] != RespMerchantNotFound {
+		t.Fatalf("write to unknown merchant = %d %v, want 422 %s", status, body, RespMerchantNotFound)
+	}
+	if _, exists := kit.Merchant(testHuifuID); exists {
+		t.Fatal("write to unknown merchant created it")
+	}
+}
+
+func TestReqSeqReplayAndConflict(t *testing.T) {
+	kit := New(t)
+	kit.SeedConfig(testSysID, "PAYUN", "test")
+	kit.SeedMerchant(Merchant{HuifuID: testHuifuID})
+
+	for i, wantReplayed := range []bool{false, true} {
+		status, body := do(t, kit, http.MethodPost, "/api/wechat-config", wechatConfig("SEQ0001", "wx0123456789abcdef"))
+		if status != http.StatusOK || body["replayed"] != wantReplayed || body["req_seq_id"] != "SEQ0001" {
+			t.Fatalf("attempt %d = %d %v, want 200 replayed=%v", i+1, status, body, wantReplayed)
+		}
+	}
+	kit.AssertCallCount(t, "/v2/merchant/busi/config", 1)
+
+	status, body := do(t, kit, http.MethodPost, "/api/wechat-config", wechatConfig("SEQ0001", "wxfedcba9876543210"))
+	if status != http.StatusConflict || body["category"] != "conflict" {
+		t.Fatalf("reused req_seq_id = %d %v, want 409 conflict", status, body)
+	}
+	kit.AssertCallCount(t, "/v2/merchant/busi/config", 1)
+}
+
+func TestClient(t *testing.T) {
+	kit := New(t)
+	kit.SeedConfig(testSysID, "PAYUN", "test")
+	kit.Respond("/v2/trade/payment/scanpay/query", map[string]interface{}{"trans_stat": "S"})
+
+	result, err := kit.Client(testSysID).CallAPI(context.Background(), "/v2/trade/payment/scanpay/query", map[string]interface{}{
+		"req_seq_id": "SEQ0002",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := result["data"].(map[string]interface{})
+	if data["trans_stat"] != "S" || data["resp_code"] != RespSuccess || data["req_seq_id"] != "SEQ0002" {
+		t.Fatalf("CallAPI data = %v", data)
+	}
+	kit.AssertCalled(t, "/v2/trade/payment/scanpay/query", map[string]interface{}{"req_seq_id": "SEQ0002"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := kit.Client(testSysID).CallAPI(ctx, "/v2/merchant/basicdata/query", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled CallAPI err = %v, want context.Canceled", err)
+	}
+}
+
+func TestImportConfig(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := pem.EncodeToMemory(block)
+
+	importRequest := func(passphrase string, file []byte) *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("sys_id", testSysID)
+		form.WriteField("product_id", "PAYUN")
+		form.WriteField("passphrase", passphrase)
+		if file != nil {
+			part, _ := form.CreateFormFile("key_file", "merchant.key")
+			part.Write(file)
+		}
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/config/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return req
+	}
+
+	kit := New(t)
+	tests := []struct {
+		name       string
+		passphrase string
+		file       []byte
+		status     int
+		field      string
+	}{
+		{"missing key file", "secret", nil, http.StatusBadRequest, "key_file"},
+		{"wrong passphrase", "wrong", keyFile, http.StatusBadRequest, "passphrase"},
+		{"correct passphrase", "secret", keyFile, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := serve(t, kit, importRequest(tt.passphrase, tt.file))
+			if status != tt.status || (tt.field != "" && body["field"] != tt.field) {
+				t.Fatalf("import = %d %v, want %d field %q", status, body, tt.status, tt.field)
+			}
+		})
+	}
+	if configs := kit.Configs(); len(configs) != 1 || configs[0].SysID != testSysID {
+		t.Fatalf("Configs() = %+v, want the imported config", configs)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"huifu-config-system/huifusign"
+	"huifu-config-system/testkit"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	contractSysID   = "6666000100000001"
+	contractImport  = "6666000100000002"
+	contractHuifuID = "6666000100000003"
+)
+
+// serverOnlyConfigFields 真实服务 /api/configs 额外返回的私钥期限及证书字段，testkit 不提供（见 testkit 包文档）
+var serverOnlyConfigFields = map[string]bool{
+	"certificate_not_after": true,
+	"key_created_at":        true,
+	"key_created_source":    true,
+	"key_loaded_at":         true,
+	"key_age_days":          true,
+	"key_status":            true,
+	"key_expires_at":        true,
+}
+
+// contractResponse 一次请求的响应
+type contractResponse struct {
+	status int
+	body   map[string]interface{}
+}
+
+// TestTestkitMatchesServer 对真实服务与 testkit 发送同一组请求，比较状态码、响应字段及错误分类
+// 真实服务经完整中间件链调用另一个 testkit 的假客户端，两边的汇付响应相同，差异只来自接口处理
+func TestTestkitMatchesServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	isolateServerState(t)
+
+	server := setupRouter()
+	upstream := testkit.New(t)
+	upstream.SeedMerchant(testkit.Merchant{HuifuID: contractSysID}, testkit.Merchant{HuifuID: contractImport}, testkit.Merchant{HuifuID: contractHuifuID})
+	kit := testkit.New(t)
+	kit.SeedMerchant(testkit.Merchant{HuifuID: contractHuifuID})
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	compare := func(name string, newRequest func() *http.Request) contractResponse {
+		t.Helper()
+		got := exchange(t, server, newRequest())
+		want := exchange(t, kit.Handler(), newRequest())
+		if got.status != want.status {
+			t.Fatalf("%s: server returned %d %v, testkit returned %d %v", name, got.status, got.body, want.status, want.body)
+		}
+		if gotKeys, wantKeys := keysOf(got.body, nil), keysOf(want.body, nil); gotKeys != wantKeys {
+			t.Errorf("%s: server fields %s, testkit fields %s", name, gotKeys, wantKeys)
+		}
+		for _, field := range []string{"category", "field", "resp_code", "replayed"} {
+			if got.body[field] != want.body[field] {
+				t.Errorf("%s: server %s=%v, testkit %s=%v", name, field, got.body[field], field, want.body[field])
+			}
+		}
+		return got
+	}
+	jsonRequest := func(method, path string, body interface{}) func() *http.Request {
+		return func() *http.Request {
+			data, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, path, bytes.NewReader(data))
+			req.Header.Set("Content-Type", "application/json")
+			return req
+		}
+	}
+	wechat := func(reqSeqID, huifuID, appID string) func() *http.Request {
+		return jsonRequest(http.MethodPost, "/api/wechat-config", map[string]interface{}{
+			"sys_id":        contractSysID,
+			"huifu_id":      huifuID,
+			"fee_type":      "02",
+			"wx_woa_app_id": appID,
+			"wx_woa_path":   "https://example.com/pay/",
+			"req_seq_id":    reqSeqID,
+		})
+	}
+
+	// 配置管理
+	compare("save config", jsonRequest(http.MethodPost, "/api/config", map[string]interface{}{
+		"sys_id": contractSysID, "product_id": "PAYUN", "rsa_private_key": privateKey,
+	}))
+	useUpstream(t, upstream, contractSysID)
+	compare("save config with invalid key", jsonRequest(http.MethodPost, "/api/config", map[string]interface{}{
+		"sys_id": contractSysID, "product_id": "PAYUN", "rsa_private_key": "not a key",
+	}))
+	compare("save config without key", jsonRequest(http.MethodPost, "/api/config", map[string]interface{}{
+		"sys_id": contractSysID, "product_id": "PAYUN",
+	}))
+	compare("save config without product_id", jsonRequest(http.MethodPost, "/api/config", map[string]interface{}{
+		"sys_id": contractSysID, "rsa_private_key": privateKey,
+	}))
+	compareConfigs(t, exchange(t, server, httptest.NewRequest(http.MethodGet, "/api/configs", nil)),
+		exchange(t, kit.Handler(), httptest.NewRequest(http.MethodGet, "/api/configs", nil)))
+
+	// 连通性测试
+	compare("test config", jsonRequest(http.MethodPost, "/api/test-config", map[string]interface{}{"sys_id": contractSysID}))
+	compare("test unknown config", jsonRequest(http.MethodPost, "/api/test-config", map[string]interface{}{"sys_id": "6666000199999999"}))
+
+	// 微信配置写入、流水号重放与冲突、未知商户
+	compare("wechat config without fee_type", jsonRequest(http.MethodPost, "/api/wechat-config", map[string]interface{}{
+		"sys_id": contractSysID, "huifu_id": contractHuifuID,
+	}))
+	compare("wechat config", wechat("CONTRACT0001", contractHuifuID, "wx0123456789abcdef"))
+	compare("wechat config replay", wechat("CONTRACT0001", contractHuifuID, "wx0123456789abcdef"))
+	compare("wechat config conflict", wechat("CONTRACT0001", contractHuifuID, "wxfedcba9876543210"))
+	compare("wechat config for unknown merchant", wechat("", "6666000199999998", "wx0123456789abcdef"))
+
+	// 微信配置查询
+	query := jsonRequest(http.MethodPost, "/api/wechat-config-query", map[string]interface{}{
+		"sys_id": contractSysID, "huifu_id": contractHuifuID, "req_seq_id": "CONTRACT0002",
+	})
+	compare("wechat config query", query)
+	compare("wechat config query replay", query)
+
+	// 通用接口调用
+	compare("call", jsonRequest(http.MethodPost, "/api/call/"+contractSysID+"/v2/merchant/basicdata/query", map[string]interface{}{
+		"huifu_id": contractHuifuID, "req_seq_id": "CONTRACT0003",
+	}))
+	compare("call replay", jsonRequest(http.MethodPost, "/api/call/"+contractSysID+"/v2/merchant/basicdata/query", map[string]interface{}{
+		"huifu_id": contractHuifuID, "req_seq_id": "CONTRACT0003",
+	}))
+	compare("call unknown endpoint", jsonRequest(http.MethodPost, "/api/call/"+contractSysID+"/v1/unknown", map[string]interface{}{}))
+
+	// 私钥文件导入
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := pem.EncodeToMemory(block)
+	compare("import without key file", importRequest(contractImport, "secret", nil))
+	compare("import with wrong passphrase", importRequest(contractImport, "wrong", keyFile))
+	compare("import", importRequest(contractImport, "secret", keyFile))
+	useUpstream(t, upstream, contractImport)
+
+	// 删除配置
+	for _, sysID := range []string{contractSysID, contractImport} {
+		compare("delete config", func() *http.Request { return httptest.NewRequest(http.MethodDelete, "/api/config/"+sysID, nil) })
+	}
+	compare("delete missing config", func() *http.Request {
+		return httptest.NewRequest(http.MethodDelete, "/api/config/"+contractSysID, nil)
+	})
+}
+
+// isolateServerState 使用内存中的调用日志、私钥登记及进程内签名器（私钥不写入SDK配置文件，且可导入私钥文件）并放行通用调用，
+// 测试结束时恢复全局状态
+func isolateServerState(t *testing.T) {
+	t.Helper()
+
+	huifuKey, err := huifusign.ParsePublicKey(huifuPublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, policy, signer, registry := callJournal, passthroughPolicy, keySigner, keyRegistry
+	callJournal, _ = OpenCallJournal("", 1000)
+	keyRegistry, _ = OpenKeyRegistry("")
+	passthroughPolicy = ParsePassthroughPolicy("*")
+	keySigner = NewLocalSigner(huifuKey)
+	t.Cleanup(func() {
+		for _, sysID := range []string{contractSysID, contractImport} {
+			configManager.DeleteConfig(sysID)
+		}
+		callJournal, passthroughPolicy, keySigner, keyRegistry = journal, policy, signer, registry
+	})
+}
+
+// useUpstream 将sys_id的客户端替换为经完整中间件链的假客户端，使真实服务不访问汇付
+func useUpstream(t *testing.T, upstream *testkit.Kit, sysID string) {
+	t.Helper()
+
+	if _, exists := configManager.GetConfig(sysID); !exists {
+		t.Fatalf("configuration for %s was not saved", sysID)
+	}
+	configManager.mu.Lock()
+	configManager.sdkClients[sysID] = buildClientChain(sysID, upstream.Client(sysID))
+	configManager.probeClients[sysID] = buildProbeChain(sysID, upstream.Client(sysID))
+	configManager.mu.Unlock()
+}
+
+func importRequest(sysID, passphrase string, keyFile []byte) func() *http.Request {
+	return func() *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("sys_id", sysID)
+		form.WriteField("product_id", "PAYUN")
+		form.WriteField("passphrase", passphrase)
+		if keyFile != nil {
+			part, _ := form.CreateFormFile("key_file", "merchant.key")
+			part.Write(keyFile)
+		}
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/config/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return req
+	}
+}
+
+func exchange(t *testing.T, handler http.Handler, req *http.Request) contractResponse {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: invalid JSON response %q: %v", req.Method, req.URL.Path, rec.Body.String(), err)
+	}
+	return contractResponse{status: rec.Code, body: body}
+}
+
+// compareConfigs 比较 /api/configs：条目字段相同（除真实服务独有的私钥期限字段）
+func compareConfigs(t *testing.T, got, want contractResponse) {
+	t.Helper()
+
+	if got.status != want.status || got.body["count"] != want.body["count"] {
+		t.Fatalf("configs: server returned %d %v, testkit returned %d %v", got.status, got.body, want.status, want.body)
+	}
+	gotConfigs, _ := got.body["configs"].([]interface{})
+	wantConfigs, _ := want.body["configs"].([]interface{})
+	for i := range gotConfigs {
+		gotEntry, _ := gotConfigs[i].(map[string]interface{})
+		wantEntry, _ := wantConfigs[i].(map[string]interface{})
+		if gotKeys, wantKeys := keysOf(gotEntry, serverOnlyConfigFields), keysOf(wantEntry, nil); gotKeys != wantKeys {
+			t.Errorf("configs: server entry fields %s, testkit entry fields %s", gotKeys, wantKeys)
+		}
+		for _, field := range []string{"sys_id", "product_id", "environment", "health", "key_source", "key_uri"} {
+			if gotEntry[field] != wantEntry[field] {
+				t.Errorf("configs: server %s=%v, testkit %s=%v", field, gotEntry[field], field, wantEntry[field])
+			}
+		}
+	}
+}
+
+// keysOf 返回排序后的字段名，跳过except中的字段
+func keysOf(body map[string]interface{}, except map[string]bool) string {
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		if !except[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// totpPeriod TOTP时间步长（RFC 6238 默认30秒）
+	totpPeriod = 30
+	// totpDigits 动态码位数
+	totpDigits = 6
+	// totpSkew 允许前后偏移的时间步数，兼容客户端时钟误差
+	totpSkew = 1
+	// totpIssuer 写入otpauth URI的签发方名称
+	totpIssuer = "HuifuConfig"
+
+	// operatorHeader 操作员标识请求头
+	operatorHeader = "X-Operator-ID"
+	// totpCodeHeader 二次验证动态码请求头
+	totpCodeHeader = "X-TOTP-Code"
+	// bootstrapTokenHeader 带外引导令牌请求头，用于绑定第一个操作员
+	bootstrapTokenHeader = "X-Bootstrap-Token"
+)
+
+// totpEnrollment 操作员的TOTP绑定信息
+type totpEnrollment struct {
+	secret      string
+	confirmed   bool
+	enrolledAt  time.Time
+	lastCounter int64 // 最近一次成功使用的时间步，防止同一动态码被重放
+}
+
+// StepUpEvent 生产环境写操作的二次验证记录
+type StepUpEvent struct {
+	Operator   string    `json:"operator"`
+	Operation  string    `json:"operation"`
+	SysID      string    `json:"sys_id"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason,omitempty"`
+	ClientIP   string    `json:"client_ip"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// TOTPManager 管理操作员TOTP绑定及二次验证事件
+type TOTPManager struct {
+	mu          sync.Mutex
+	enrollments map[string]*totpEnrollment
+	events      []StepUpEvent
+	maxEvents   int
+	now         func() time.Time
+}
+
+// NewTOTPManager 创建TOTP管理器
+func NewTOTPManager() *TOTPManager {
+	return &TOTPManager{
+		enrollments: make(map[string]*totpEnrollment),
+		maxEvents:   1000,
+		now:         time.Now,
+	}
+}
+
+// Enroll 为操作员生成新的TOTP密钥，需调用Confirm后才生效
+func (m *TOTPManager) Enroll(operator string) (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %v", err)
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.enrollments[operator]; ok {
+		if existing.confirmed {
+			return "", fmt.Errorf("operator %s already has a confirmed TOTP enrollment", operator)
+		}
+		return "", fmt.Errorf("operator %s has a pending TOTP enrollment, revoke it before enrolling again", operator)
+	}
+	m.enrollments[operator] = &totpEnrollment{
+		secret:     secret,
+		enrolledAt: m.now(),
+	}
+	return secret, nil
+}
+
+// Confirm 使用一个有效动态码确认绑定
+func (m *TOTPManager) Confirm(operator, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, ok := m.enrollments[operator]
+	if !ok {
+		return fmt.Errorf("no TOTP enrollment for operator: %s", operator)
+	}
+	if err := m.verifyLocked(enrollment, code); err != nil {
+		return err
+	}
+	enrollment.confirmed = true
+	return nil
+}
+
+// IsEnrolled 判断操作员是否已完成TOTP绑定
+func (m *TOTPManager) IsEnrolled(operator string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, ok := m.enrollments[operator]
+	return ok && enrollment.confirmed
+}
+
+// Verify 校验已绑定操作员的动态码
+func (m *TOTPManager) Verify(operator, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	enrollment, ok := m.enrollments[operator]
+	if !ok || !enrollment.confirmed {
+		return fmt.Errorf("operator %s has not enrolled TOTP", operator)
+	}
+	return m.verifyLocked(enrollment, code)
+}
+
+// Revoke 解除操作员的TOTP绑定
+func (m *TOTPManager) Revoke(operator string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.enrollments[operator]; !ok {
+		return fmt.Errorf("no TOTP enrollment for operator: %s", operator)
+	}
+	delete(m.enrollments, operator)
+	return nil
+}
+
+// verifyLocked 校验动态码，调用方需持有锁
+func (m *TOTPManager) verifyLocked(enrollment *totpEnrollment, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return fmt.Errorf("TOTP code must be %d digits", totpDigits)
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.secret)
+	if err != nil {
+		return fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	current := m.now().Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		counter := current + offset
+		if !hmac.Equal([]byte(totpCode(key, counter)), []byte(code)) {
+			continue
+		}
+		if counter <= enrollment.lastCounter {
+			return fmt.Errorf("TOTP code has already been used")
+		}
+		enrollment.lastCounter = counter
+		return nil
+	}
+	return fmt.Errorf("invalid TOTP code")
+}
+
+// RecordEvent 记录一次二次验证事件
+func (m *TOTPManager) RecordEvent(event StepUpEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, event)
+	if len(m.events) > m.maxEvents {
+		m.events = m.events[len(m.events)-m.maxEvents:]
+	}
+}
+
+// Events 返回二次验证事件（按时间倒序）
+func (m *TOTPManager) Events() []StepUpEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]StepUpEvent, 0, len(m.events))
+	for i := len(m.events) - 1; i >= 0; i-- {
+		events = append(events, m.events[i])
+	}
+	return events
+}
+
+// totpCode 按 RFC 4226 计算指定计数器的动态码
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// totpURI 生成认证器App可识别的otpauth URI
+func totpURI(operator, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + operator)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("digits", fmt.Sprintf("%d", totpDigits))
+	query.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+var totpManager = NewTOTPManager()
+
+// authorizeTOTPAdmin 校验TOTP绑定管理请求的授权：已绑定操作员的 X-Operator-ID 与 X-TOTP-Code，
+// 或与 HUIFU_TOTP_BOOTSTRAP_TOKEN 一致的 X-Bootstrap-Token（用于绑定第一个操作员）
+// 返回授权方名称，操作员标识不能自行注册
+func authorizeTOTPAdmin(c *gin.Context) (string, error) {
+	if token := c.GetHeader(bootstrapTokenHeader); token != "" {
+		expected := os.Getenv("HUIFU_TOTP_BOOTSTRAP_TOKEN")
+		if expected == "" {
+			return "", fmt.Errorf("bootstrap token is not configured on this server")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			return "", fmt.Errorf("invalid bootstrap token")
+		}
+		return "bootstrap", nil
+	}
+
+	operator := strings.TrimSpace(c.GetHeader(operatorHeader))
+	if operator == "" {
+		return "", fmt.Errorf("%s and %s of an enrolled operator, or %s, are required", operatorHeader, totpCodeHeader, bootstrapTokenHeader)
+	}
+	if err := totpManager.Verify(operator, c.GetHeader(totpCodeHeader)); err != nil {
+		return "", err
+	}
+	return operator, nil
+}
+
+// requireStepUp 对生产环境写操作要求新鲜的TOTP动态码
+// 非生产环境直接放行；校验失败时写入响应并返回false
+func requireStepUp(c *gin.Context, operation, sysID, environment string) bool {
+	if environment != "production" {
+		return true
+	}
+
+	operator := strings.TrimSpace(c.GetHeader(operatorHeader))
+	event := StepUpEvent{
+		Operator:   operator,
+		Operation:  operation,
+		SysID:      sysID,
+		ClientIP:   c.ClientIP(),
+		OccurredAt: time.Now(),
+	}
+
+	var err error
+	switch {
+	case operator == "":
+		err = fmt.Errorf("%s header is required for production operations", operatorHeader)
+	case c.GetHeader(totpCodeHeader) == "":
+		err = fmt.Errorf("%s header is required for production operations", totpCodeHeader)
+	default:
+		err = totpManager.Verify(operator, c.GetHeader(totpCodeHeader))
+	}
+
+	if err != nil {
+		event.Reason = err.Error()
+		totpManager.RecordEvent(event)
+		log.Printf("Step-up verification failed: operation=%s sys_id=%s operator=%s: %v", operation, sysID, operator, err)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Step-up verification required",
+			"details": err.Error(),
+		})
+		return false
+	}
+
+	event.Success = true
+	totpManager.RecordEvent(event)
+	log.Printf("Step-up verified: operation=%s sys_id=%s operator=%s", operation, sysID, operator)
+	c.Set("step_up_operator", operator)
+	c.Request = c.Request.WithContext(withStepUp(WithActor(c.Request.Context(), operator)))
+	return true
+}
+
+// enrollTOTP 为操作员生成TOTP密钥，需已绑定操作员或引导令牌授权
+func enrollTOTP(c *gin.Context) {
+	var req struct {
+		Operator string `json:"operator" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	event := StepUpEvent{
+		Operator:   req.Operator,
+		Operation:  "enroll_totp",
+		ClientIP:   c.ClientIP(),
+		OccurredAt: time.Now(),
+	}
+	authorizer, err := authorizeTOTPAdmin(c)
+	if err != nil {
+		event.Reason = err.Error()
+		totpManager.RecordEvent(event)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Enrollment not authorized",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	secret, err := totpManager.Enroll(req.Operator)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Failed to enroll TOTP",
+			"details": err.Error(),
+		})
+		return
+	}
+	event.Success = true
+	event.Reason = "authorized by " + authorizer
+	totpManager.RecordEvent(event)
+	log.Printf("TOTP enrollment created: operator=%s authorized_by=%s", req.Operator, authorizer)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "TOTP enrollment created, confirm it with a code from your authenticator",
+		"operator":    req.Operator,
+		"secret":      secret,
+		"otpauth_uri": totpURI(req.Operator, secret),
+	})
+}
+
+// confirmTOTP 确认操作员的TOTP绑定
+func confirmTOTP(c *gin.Context) {
+	var req struct {
+		Operator string `json:"operator" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := totpManager.Confirm(req.Operator, req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to confirm TOTP",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "TOTP enrollment confirmed",
+		"operator": req.Operator,
+	})
+}
+
+// revokeTOTP 解除操作员的TOTP绑定
+// 已确认的绑定可由本人（仅带 X-TOTP-Code）或其他已绑定操作员解除；待确认的绑定须由已绑定操作员或引导令牌解除
+func revokeTOTP(c *gin.Context) {
+	operator := c.Param("operator")
+	if c.GetHeader(operatorHeader) == "" && c.GetHeader(bootstrapTokenHeader) == "" {
+		c.Request.Header.Set(operatorHeader, operator)
+	}
+
+	authorizer, err := authorizeTOTPAdmin(c)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Step-up verification required",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := totpManager.Revoke(operator); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "TOTP enrollment not found",
+			"details": err.Error(),
+		})
+		return
+	}
+	totpManager.RecordEvent(StepUpEvent{
+		Operator:   operator,
+		Operation:  "revoke_totp",
+		Success:    true,
+		Reason:     "authorized by " + authorizer,
+		ClientIP:   c.ClientIP(),
+		OccurredAt: time.Now(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "TOTP enrollment revoked",
+		"operator": operator,
+	})
+}
+
+// getStepUpEvents 查询二次验证事件
+func getStepUpEvents(c *gin.Context) {
+	events := totpManager.Events()
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testClock 测试用的固定时钟，可手动推进
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// useTOTPManager 在测试期间以固定时钟的TOTP管理器替换全局管理器
+func useTOTPManager(t *testing.T) (*TOTPManager, *testClock) {
+	t.Helper()
+
+	clock := &testClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	manager := NewTOTPManager()
+	manager.now = clock.Now
+	saved := totpManager
+	totpManager = manager
+	t.Cleanup(func() { totpManager = saved })
+	return manager, clock
+}
+
+// codeAt 计算密钥在指定时间的动态码
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, at.Unix()/totpPeriod)
+}
+
+// enrollOperator 绑定并确认操作员，确认时使用的时间步此后不可再用
+func enrollOperator(t *testing.T, manager *TOTPManager, clock *testClock, operator string) string {
+	t.Helper()
+
+	secret, err := manager.Enroll(operator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Confirm(operator, codeAt(t, secret, clock.now)); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestTOTPVerify(t *testing.T) {
+	manager, clock := useTOTPManager(t)
+	secret := enrollOperator(t, manager, clock, "alice")
+	confirmedAt := clock.now
+
+	if _, err := manager.Enroll("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.now = clock.now.Add(totpPeriod * time.Second)
+	tests := []struct {
+		name     string
+		operator string
+		code     string
+		wantErr  string
+	}{
+		{"code used to confirm", "alice", codeAt(t, secret, confirmedAt), "already been used"},
+		{"wrong code", "alice", "000000", "invalid TOTP code"},
+		{"short code", "alice", "12345", "must be 6 digits"},
+		{"unconfirmed operator", "bob", "123456", "has not enrolled"},
+		{"unknown operator", "carol", "123456", "has not enrolled"},
+		{"fresh code", "alice", codeAt(t, secret, clock.now), ""},
+		{"fresh code reused", "alice", codeAt(t, secret, clock.now), "already been used"},
+		{"earlier step within the skew", "alice", codeAt(t, secret, confirmedAt), "already been used"},
+	}
+	for _, tt := range tests {
+		err := manager.Verify(tt.operator, tt.code)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// 下一个时间步的动态码可用
+	clock.now = clock.now.Add(totpPeriod * time.Second)
+	if err := manager.Verify("alice", codeAt(t, secret, clock.now)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequireStepUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager, clock := useTOTPManager(t)
+	secret := enrollOperator(t, manager, clock, "alice")
+	clock.now = clock.now.Add(totpPeriod * time.Second)
+	fresh := codeAt(t, secret, clock.now)
+
+	tests := []struct {
+		name        string
+		environment string
+		operator    string
+		code        string
+		wantOK      bool
+	}{
+		{"test environment without headers", "test", "", "", true},
+		{"empty environment without headers", "", "", "", true},
+		{"production without headers", "production", "", "", false},
+		{"production without code", "production", "alice", "", false},
+		{"production with a wrong code", "production", "alice", "000000", false},
+		{"production with an unknown operator", "production", "mallory", fresh, false},
+		{"production with a fresh code", "production", "alice", fresh, true},
+		{"production with the same code again", "production", "alice", fresh, false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/config", nil)
+		if tt.operator != "" {
+			c.Request.Header.Set(operatorHeader, tt.operator)
+		}
+		if tt.code != "" {
+			c.Request.Header.Set(totpCodeHeader, tt.code)
+		}
+
+		if ok := requireStepUp(c, "save_config", contractSysID, tt.environment); ok != tt.wantOK {
+			t.Errorf("%s: requireStepUp = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if !tt.wantOK && w.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403", tt.name, w.Code)
+		}
+		if tt.wantOK && tt.environment == "production" && c.GetString("step_up_operator") != tt.operator {
+			t.Errorf("%s: step_up_operator = %q", tt.name, c.GetString("step_up_operator"))
+		}
+	}
+
+	// 非生产环境不记录事件，生产环境每次校验均记录
+	if events := manager.Events(); len(events) != 6 || !events[1].Success || events[0].Success {
+		t.Errorf("events = %+v, want 6 with only the fresh code succeeding", events)
+	}
+}
+
+func TestTOTPEnrollmentAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager, clock := useTOTPManager(t)
+	t.Setenv("HUIFU_TOTP_BOOTSTRAP_TOKEN", "bootstrap-secret")
+	server := setupRouter()
+
+	enroll := func(operator string, headers map[string]string) contractResponse {
+		req := jsonBody(t, http.MethodPost, "/api/totp/enroll", map[string]string{"operator": operator})
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return exchange(t, server, req)
+	}
+
+	// 无授权及错误的引导令牌均被拒绝，操作员不能自行注册
+	if got := enroll("alice", nil); got.status != http.StatusForbidden {
+		t.Fatalf("enroll without authorization = %d %v", got.status, got.body)
+	}
+	if got := enroll("alice", map[string]string{bootstrapTokenHeader: "guess"}); got.status != http.StatusForbidden {
+		t.Fatalf("enroll with a wrong bootstrap token = %d %v", got.status, got.body)
+	}
+	if got := enroll("alice", map[string]string{operatorHeader: "alice", totpCodeHeader: "123456"}); got.status != http.StatusForbidden {
+		t.Fatalf("self-enrollment = %d %v", got.status, got.body)
+	}
+
+	// 引导令牌绑定第一个操作员
+	got := enroll("alice", map[string]string{bootstrapTokenHeader: "bootstrap-secret"})
+	if got.status != http.StatusOK {
+		t.Fatalf("enroll with the bootstrap token = %d %v", got.status, got.body)
+	}
+	secret, _ := got.body["secret"].(string)
+	if err := manager.Confirm("alice", codeAt(t, secret, clock.now)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已绑定操作员以新的动态码授权绑定其他操作员，已用过的动态码无效
+	clock.now = clock.now.Add(totpPeriod * time.Second)
+	code := codeAt(t, secret, clock.now)
+	if got := enroll("bob", map[string]string{operatorHeader: "alice", totpCodeHeader: code}); got.status != http.StatusOK {
+		t.Fatalf("enroll authorized by an operator = %d %v", got.status, got.body)
+	}
+	if got := enroll("carol", map[string]string{operatorHeader: "alice", totpCodeHeader: code}); got.status != http.StatusForbidden {
+		t.Fatalf("enroll with a reused code = %d %v", got.status, got.body)
+	}
+	if got := enroll("carol", map[string]string{operatorHeader: "bob", totpCodeHeader: code}); got.status != http.StatusForbidden {
+		t.Fatalf("enroll authorized by an unconfirmed operator = %d %v", got.status, got.body)
+	}
+}
@@ -1,16 +1,39 @@
 package main
 
+import (
+	"context"
+	"time"
+)
+
 // ConfigRequest 配置请求结构体
 type ConfigRequest struct {
-	SysID         string `json:"sys_id" binding:"required"`
-	ProductID     string `json:"product_id" binding:"required"`
-	RSAPrivateKey string `json:"rsa_private_key" binding:"required"`
-	WxWoaAppID    string `json:"wx_woa_app_id"`    // 可选，微信小程序AppID
-	WxWoaPath     string `json:"wx_woa_path"`     // 可选，微信小程序路径
-	Environment   string `json:"environment"` // production or test
+	SysID            string           `json:"sys_id" binding:"required"`
+	ProductID        string           `json:"product_id" binding:"required"`
+	RSAPrivateKey    string           `json:"rsa_private_key"`     // 未给出私钥引用且签名守护进程未预置私钥时必填
+	RSAPrivateKeyURI string           `json:"rsa_private_key_uri"` // 可选，私钥引用：env:NAME、file:///path、vault://mount/path#field
+	Certificate      *CertificateInfo `json:"-"`                   // 导入私钥文件时附带的证书
+	PublicKey        string           `json:"-"`                   // 由私钥导出的公钥（base64 PKIX DER）
+	KeyCreatedAt     time.Time        `json:"key_created_at"`      // 可选，私钥生成时间（RFC3339），用于计算私钥使用期限
+	KeyCreatedSource string           `json:"-"`                   // 创建时间的来源，见 KeyCreatedFrom*
+	KeyLoadedAt      time.Time        `json:"-"`                   // 私钥最近一次加载的时间
+	WxWoaAppID       string           `json:"wx_woa_app_id"`       // 可选，微信小程序AppID
+	WxWoaPath        string           `json:"wx_woa_path"`         // 可选，微信小程序路径
+	Environment      string           `json:"environment"`         // production or test
 }
 
 // HuifuClient SDK客户端接口
+// 实现需遵守ctx的截止时间与取消，超时以 *CallTimeoutError 返回并注明所处阶段
 type HuifuClient interface {
-	CallAPI(endpoint string, params map[string]interface{}) (map[string]interface{}, error)
-}
\ No newline at end of file
+	CallAPI(ctx context.Context, endpoint string, params map[string]interface{}) (map[string]interface{}, error)
+}
+
+// unwrapClient 剥离装饰层，返回最内层的客户端实现
+func unwrapClient(client HuifuClient) HuifuClient {
+	for {
+		wrapper, ok := client.(interface{ Unwrap() HuifuClient })
+		if !ok {
+			return client
+		}
+		client = wrapper.Unwrap()
+	}
+}
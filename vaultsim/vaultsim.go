@@ -0,0 +1,259 @@
+// Package vaultsim 本地Vault KV v2模拟服务
+//
+// 实现读取私钥所需的最小子集：KV v2 secret的读写（/v1/<mount>/data/<path>）、
+// X-Vault-Token 校验及令牌查询与续期（/v1/auth/token/lookup-self、renew-self）。
+// 与真实Vault一致，KV v2读取不返回租约（lease_id为空、renewable为false、lease_duration为0）；
+// tokenTTL大于0时令牌到期后拒绝请求，用于测试令牌续期；可独立运行（cmd/vault-sim）或在测试中嵌入：
+//
+//	server := vaultsim.New("root", time.Hour)
+//	server.Put("secret", "huifu/6666000100000000", map[string]interface{}{"private_key": pem})
+//	ts := httptest.NewServer(server)
+package vaultsim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// secret 一个KV v2 secret的当前版本
+type secret struct {
+	data      map[string]interface{}
+	version   int
+	createdAt time.Time
+}
+
+// Server Vault模拟服务
+type Server struct {
+	mu             sync.Mutex
+	token          string
+	tokenTTL       time.Duration
+	tokenExpiresAt time.Time
+	secrets        map[string]*secret // <mount>/<path> -> secret
+	seq            int
+	engine         *gin.Engine
+}
+
+// New 创建模拟服务；token为空时不校验令牌，tokenTTL为0时令牌永不过期且不可续期（同root令牌）
+func New(token string, tokenTTL time.Duration) *Server {
+	s := &Server{
+		token:    token,
+		tokenTTL: tokenTTL,
+		secrets:  make(map[string]*secret),
+	}
+	if tokenTTL > 0 {
+		s.tokenExpiresAt = time.Now().Add(tokenTTL)
+	}
+
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.Any("/v1/*path", s.handle)
+	s.engine = engine
+	return s
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.engine.ServeHTTP(w, r)
+}
+
+// Put 写入secret的新版本，返回版本号
+func (s *Server) Put(mount, path string, data map[string]interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(secretKey(mount, path), data)
+}
+
+// Get 返回secret当前版本的数据
+func (s *Server) Get(mount, path string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, exists := s.secrets[secretKey(mount, path)]
+	if !exists {
+		return nil, false
+	}
+	return current.data, true
+}
+
+// TokenExpiresAt 返回令牌的到期时间，永不过期时为零值
+func (s *Server) TokenExpiresAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokenExpiresAt
+}
+
+// ExpireToken 令令牌立即到期，之后的请求（包括续期）均被拒绝
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenExpiresAt = time.Now()
+}
+
+// tokenValid 令牌未设置到期时间或尚未到期
+func (s *Server) tokenValid() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokenExpiresAt.IsZero() || time.Now().Before(s.tokenExpiresAt)
+}
+
+func (s *Server) put(key string, data map[string]interface{}) int {
+	current, exists := s.secrets[key]
+	version := 1
+	if exists {
+		version = current.version + 1
+	}
+	s.secrets[key] = &secret{data: data, version: version, createdAt: time.Now().UTC()}
+	return version
+}
+
+func (s *Server) handle(c *gin.Context) {
+	if s.token != "" && (c.GetHeader("X-Vault-Token") != s.token || !s.tokenValid()) {
+		writeErrors(c, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	path := strings.Trim(c.Param("path"), "/")
+	switch path {
+	case "auth/token/lookup-self":
+		if c.Request.Method != http.MethodGet {
+			writeErrors(c, http.StatusMethodNotAllowed, "unsupported operation")
+			return
+		}
+		s.lookupSelf(c)
+		return
+	case "auth/token/renew-self":
+		if c.Request.Method != http.MethodPut && c.Request.Method != http.MethodPost {
+			writeErrors(c, http.StatusMethodNotAllowed, "unsupported operation")
+			return
+		}
+		s.renewSelf(c)
+		return
+	}
+
+	mount, secretPath, ok := strings.Cut(path, "/data/")
+	if !ok || mount == "" || secretPath == "" {
+		writeErrors(c, http.StatusNotFound, fmt.Sprintf("no handler for route %q", path))
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet:
+		s.read(c, secretKey(mount, secretPath))
+	case http.MethodPut, http.MethodPost:
+		s.write(c, secretKey(mount, secretPath))
+	default:
+		writeErrors(c, http.StatusMethodNotAllowed, "unsupported operation")
+	}
+}
+
+// read GET /v1/<mount>/data/<path>
+func (s *Server) read(c *gin.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.secrets[key]
+	if !exists {
+		// Vault对不存在的secret返回空errors
+		writeErrors(c, http.StatusNotFound)
+		return
+	}
+
+	// KV v2的secret没有租约
+	s.seq++
+	c.JSON(http.StatusOK, gin.H{
+		"request_id":     fmt.Sprintf("vaultsim-%d", s.seq),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data": gin.H{
+			"data": current.data,
+			"metadata": gin.H{
+				"version":      current.version,
+				"created_time": current.createdAt.Format(time.RFC3339Nano),
+			},
+		},
+	})
+}
+
+// write PUT/POST /v1/<mount>/data/<path>，请求体为 {"data": {...}}
+func (s *Server) write(c *gin.Context, key string) {
+	var req struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Data == nil {
+		writeErrors(c, http.StatusBadRequest, "no data provided")
+		return
+	}
+
+	s.mu.Lock()
+	version := s.put(key, req.Data)
+	createdAt := s.secrets[key].createdAt
+	s.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"version":      version,
+			"created_time": createdAt.Format(time.RFC3339Nano),
+		},
+	})
+}
+
+// lookupSelf GET /v1/auth/token/lookup-self，ttl为剩余秒数
+func (s *Server) lookupSelf(c *gin.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	c.JSON(http.StatusOK, gin.H{
+		"request_id": fmt.Sprintf("vaultsim-%d", s.seq),
+		"data": gin.H{
+			"ttl":          s.remainingTTL(),
+			"creation_ttl": int(s.tokenTTL / time.Second),
+			"renewable":    s.tokenTTL > 0,
+		},
+	})
+}
+
+// renewSelf PUT/POST /v1/auth/token/renew-self，将令牌有效期延长至 tokenTTL
+func (s *Server) renewSelf(c *gin.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokenTTL == 0 {
+		writeErrors(c, http.StatusBadRequest, "lease is not renewable")
+		return
+	}
+	s.tokenExpiresAt = time.Now().Add(s.tokenTTL)
+	s.seq++
+	c.JSON(http.StatusOK, gin.H{
+		"request_id": fmt.Sprintf("vaultsim-%d", s.seq),
+		"auth": gin.H{
+			"client_token":   s.token,
+			"lease_duration": s.remainingTTL(),
+			"renewable":      true,
+		},
+	})
+}
+
+// remainingTTL 令牌剩余秒数，永不过期时为0；调用方须持有s.mu
+func (s *Server) remainingTTL() int {
+	if s.tokenExpiresAt.IsZero() {
+		return 0
+	}
+	return int(time.Until(s.tokenExpiresAt).Round(time.Second) / time.Second)
+}
+
+func secretKey(mount, path string) string {
+	return strings.Trim(mount, "/") + "/" + strings.Trim(path, "/")
+}
+
+func writeErrors(c *gin.Context, status int, errors ...string) {
+	if errors == nil {
+		errors = []string{}
+	}
+	c.JSON(status, gin.H{"errors": errors})
+}